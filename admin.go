@@ -0,0 +1,157 @@
+package httptest
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPathPrefix is the path prefix of the admin API.
+const AdminPathPrefix = "/__admin"
+
+// LoggedRequest is a call made to a registered path.
+type LoggedRequest struct {
+	Method string `json:"method"`
+	// Path is the registered path, e.g. "/users/:id".
+	Path string `json:"path"`
+	RequestMade
+}
+
+// ScenarioState is the body of the admin API to set a scenario state.
+type ScenarioState struct {
+	State string `json:"state"`
+}
+
+// adminError is the body of the admin API error responses.
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// GetAllCalls returns the calls for all paths, sorted by method & path.
+func (s *Server) GetAllCalls() []LoggedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []LoggedRequest
+	for method, paths := range s.calls {
+		for path, requests := range paths {
+			for _, r := range requests {
+				calls = append(calls, LoggedRequest{Method: method, Path: path, RequestMade: r})
+			}
+		}
+	}
+
+	// Stable sort to keep the order of the calls of the same path.
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].Method != calls[j].Method {
+			return calls[i].Method < calls[j].Method
+		}
+		return calls[i].Path < calls[j].Path
+	})
+
+	return calls
+}
+
+// registerAdminRoutes registers the admin API routes to the engine.
+func (s *Server) registerAdminRoutes(e *gin.Engine) {
+	g := e.Group(AdminPathPrefix)
+
+	g.GET("/mappings", s.adminListMappings)
+	g.POST("/mappings", s.adminCreateMapping)
+	g.GET("/mappings/:id", s.adminGetMapping)
+	g.DELETE("/mappings/:id", s.adminDeleteMapping)
+
+	g.GET("/requests", s.adminListRequests)
+	g.POST("/requests/reset", s.adminResetRequests)
+
+	g.GET("/scenarios", s.adminListScenarios)
+	g.PUT("/scenarios/:name/state", s.adminSetScenarioState)
+	g.POST("/scenarios/reset", s.adminResetScenarios)
+
+	g.POST("/reset", s.adminResetAll)
+}
+
+func (s *Server) adminListMappings(c *gin.Context) {
+	c.JSON(http.StatusOK, mappingFile{Mappings: s.GetMappings()})
+}
+
+func (s *Server) adminCreateMapping(c *gin.Context) {
+	var m Mapping
+	if err := c.ShouldBindJSON(&m); err != nil {
+		c.JSON(http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	id, err := s.RegisterMapping(m)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+	m.ID = id
+
+	c.JSON(http.StatusCreated, m)
+}
+
+func (s *Server) adminGetMapping(c *gin.Context) {
+	m, ok := s.GetMapping(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, adminError{Error: "mapping not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, m)
+}
+
+func (s *Server) adminDeleteMapping(c *gin.Context) {
+	if !s.RemoveMapping(c.Param("id")) {
+		c.JSON(http.StatusNotFound, adminError{Error: "mapping not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// adminListRequests lists the calls, optionally filtered by the method & path query params.
+func (s *Server) adminListRequests(c *gin.Context) {
+	method, path := c.Query("method"), c.Query("path")
+
+	requests := []LoggedRequest{}
+	for _, r := range s.GetAllCalls() {
+		if (method == "" || r.Method == method) && (path == "" || r.Path == path) {
+			requests = append(requests, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+func (s *Server) adminResetRequests(c *gin.Context) {
+	s.ResetCalls()
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) adminListScenarios(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"scenarios": s.GetScenarios()})
+}
+
+func (s *Server) adminSetScenarioState(c *gin.Context) {
+	var body ScenarioState
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	s.SetScenarioState(c.Param("name"), body.State)
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) adminResetScenarios(c *gin.Context) {
+	s.ResetScenarios()
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) adminResetAll(c *gin.Context) {
+	s.ResetAll()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package httptest_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+	"github.com/slzhffktm/go-http-test/adminclient"
+)
+
+func (s *serverTestSuite) TestAdmin_Mappings() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{EnableAdmin: true})
+	s.NoError(err)
+	defer server.Close()
+
+	admin := adminclient.New(baseURL, nil)
+
+	m, err := admin.CreateMapping(ctx, httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users/:id"},
+		Response: httptest.MappingResponse{Status: http.StatusOK, JSONBody: json.RawMessage(`{"name":"abcd"}`)},
+	})
+	s.NoError(err)
+	s.NotEmpty(m.ID)
+
+	mappings, err := admin.ListMappings(ctx)
+	s.NoError(err)
+	s.Equal([]httptest.Mapping{m}, mappings)
+
+	res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/users/1?q=1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.JSONEq(`{"name":"abcd"}`, string(resBody))
+
+	calls, err := admin.GetCalls(ctx, http.MethodGet, "/users/:id")
+	s.NoError(err)
+	s.Equal(1, len(calls))
+	s.Equal("1", calls[0].Params["id"])
+	s.Equal("1", calls[0].Query.Get("q"))
+
+	// Admin calls are not recorded.
+	nCalls, err := admin.GetNCalls(ctx, "", "")
+	s.NoError(err)
+	s.Equal(1, nCalls)
+
+	s.NoError(admin.ResetCalls(ctx))
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/users/:id"))
+
+	s.NoError(admin.DeleteMapping(ctx, m.ID))
+	s.Error(admin.DeleteMapping(ctx, m.ID))
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	// The path of the deleted mapping is not found, like it was never registered.
+	nCalls, err = admin.GetNCalls(ctx, http.MethodGet, "/users/:id")
+	s.NoError(err)
+	s.Equal(0, nCalls)
+
+	_, err = admin.CreateMapping(ctx, httptest.Mapping{
+		Request: httptest.MappingRequest{Method: http.MethodGet, Path: "no-slash"},
+	})
+	s.Error(err)
+}
+
+func (s *serverTestSuite) TestAdmin_Scenarios() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{EnableAdmin: true})
+	s.NoError(err)
+	defer server.Close()
+
+	admin := adminclient.New(baseURL, nil)
+
+	_, err = admin.CreateMapping(ctx, httptest.Mapping{
+		Request:       httptest.MappingRequest{Method: http.MethodGet, Path: "/order"},
+		Response:      httptest.MappingResponse{Body: "pending"},
+		Scenario:      "order",
+		RequiredState: httptest.ScenarioStarted,
+		NewState:      "paid",
+	})
+	s.NoError(err)
+	_, err = admin.CreateMapping(ctx, httptest.Mapping{
+		Request:       httptest.MappingRequest{Method: http.MethodGet, Path: "/order"},
+		Response:      httptest.MappingResponse{Body: "paid"},
+		Scenario:      "order",
+		RequiredState: "paid",
+	})
+	s.NoError(err)
+
+	_, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/order", nil, nil, nil)
+	s.NoError(err)
+	s.Equal("pending", string(resBody))
+	_, resBody, err = s.httpClient.Do(ctx, http.MethodGet, "/order", nil, nil, nil)
+	s.NoError(err)
+	s.Equal("paid", string(resBody))
+
+	scenarios, err := admin.GetScenarios(ctx)
+	s.NoError(err)
+	s.Equal(map[string]string{"order": "paid"}, scenarios)
+
+	s.NoError(admin.SetScenarioState(ctx, "order", httptest.ScenarioStarted))
+	_, resBody, err = s.httpClient.Do(ctx, http.MethodGet, "/order", nil, nil, nil)
+	s.NoError(err)
+	s.Equal("pending", string(resBody))
+
+	s.NoError(admin.ResetAll(ctx))
+	mappings, err := admin.ListMappings(ctx)
+	s.NoError(err)
+	s.Empty(mappings)
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/order", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestAdmin_Disabled() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	_, err = adminclient.New(baseURL, nil).ListMappings(ctx)
+	s.ErrorContains(err, "404")
+}
@@ -0,0 +1,185 @@
+// Package adminclient is a Go client for the go-http-test admin API,
+// to manage a Server running in another process, e.g. cmd/go-http-test.
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+// Client calls the admin API of a server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a new admin client.
+// baseURL is the base URL of the server, e.g. "http://localhost:8080".
+// If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + httptest.AdminPathPrefix,
+		httpClient: httpClient,
+	}
+}
+
+// CreateMapping registers a mapping and returns it with its ID.
+func (c *Client) CreateMapping(ctx context.Context, m httptest.Mapping) (httptest.Mapping, error) {
+	var res httptest.Mapping
+	if err := c.do(ctx, http.MethodPost, "/mappings", m, &res); err != nil {
+		return httptest.Mapping{}, err
+	}
+
+	return res, nil
+}
+
+// ListMappings returns all the registered mappings.
+func (c *Client) ListMappings(ctx context.Context) ([]httptest.Mapping, error) {
+	var res struct {
+		Mappings []httptest.Mapping `json:"mappings"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/mappings", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Mappings, nil
+}
+
+// GetMapping returns the mapping with the given ID.
+func (c *Client) GetMapping(ctx context.Context, id string) (httptest.Mapping, error) {
+	var res httptest.Mapping
+	if err := c.do(ctx, http.MethodGet, "/mappings/"+url.PathEscape(id), nil, &res); err != nil {
+		return httptest.Mapping{}, err
+	}
+
+	return res, nil
+}
+
+// DeleteMapping removes the mapping with the given ID.
+func (c *Client) DeleteMapping(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/mappings/"+url.PathEscape(id), nil, nil)
+}
+
+// GetCalls returns the calls made to a registered path.
+// Empty method or path matches all of them.
+func (c *Client) GetCalls(ctx context.Context, method, path string) ([]httptest.LoggedRequest, error) {
+	q := url.Values{}
+	if method != "" {
+		q.Set("method", method)
+	}
+	if path != "" {
+		q.Set("path", path)
+	}
+
+	var res struct {
+		Requests []httptest.LoggedRequest `json:"requests"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/requests?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Requests, nil
+}
+
+// GetNCalls returns the number of calls made to a registered path.
+func (c *Client) GetNCalls(ctx context.Context, method, path string) (int, error) {
+	calls, err := c.GetCalls(ctx, method, path)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(calls), nil
+}
+
+// ResetCalls resets the calls for all paths, same as Server.ResetCalls.
+func (c *Client) ResetCalls(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/requests/reset", nil, nil)
+}
+
+// ResetAll resets all the calls, handlers, mappings and scenarios, same as Server.ResetAll.
+func (c *Client) ResetAll(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/reset", nil, nil)
+}
+
+// GetScenarios returns the state of all the scenarios.
+func (c *Client) GetScenarios(ctx context.Context) (map[string]string, error) {
+	var res struct {
+		Scenarios map[string]string `json:"scenarios"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/scenarios", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Scenarios, nil
+}
+
+// SetScenarioState sets the state of a scenario.
+func (c *Client) SetScenarioState(ctx context.Context, scenario, state string) error {
+	return c.do(
+		ctx,
+		http.MethodPut,
+		"/scenarios/"+url.PathEscape(scenario)+"/state",
+		httptest.ScenarioState{State: state},
+		nil,
+	)
+}
+
+// ResetScenarios moves all the scenarios back to httptest.ScenarioStarted.
+func (c *Client) ResetScenarios(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/scenarios/reset", nil, nil)
+}
+
+// do sends the request with reqBody marshalled to JSON, and unmarshals the response into resBody.
+func (c *Client) do(ctx context.Context, method, path string, reqBody any, resBody any) error {
+	var body io.Reader
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("httpClient.Do: %w", err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: status code %d, res body: %s", method, path, res.StatusCode, string(b))
+	}
+
+	if resBody != nil {
+		if err := json.Unmarshal(b, resBody); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	return nil
+}
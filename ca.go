@@ -0,0 +1,203 @@
+package httptest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Validities of the generated certificates, long enough for the servers
+// running for long, e.g. the standalone binary.
+const (
+	caValidity = 10 * 365 * 24 * time.Hour
+	// certificateValidity is the validity of the issued certificates, within
+	// the limit of the clients, e.g. 825 days on macOS.
+	certificateValidity = 397 * 24 * time.Hour
+	// certificateRenewal is how long before they expire the cached server
+	// certificates are issued again.
+	certificateRenewal = 24 * time.Hour
+)
+
+// CertificateAuthority is an in-memory certificate authority to issue the
+// certificates of the server, without having to manage files.
+type CertificateAuthority struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+
+	// leafs cache the issued server certificates by hosts.
+	leafs map[string]*tls.Certificate
+	mu    sync.Mutex
+}
+
+// NewCertificateAuthority generates a new self-signed certificate authority.
+func NewCertificateAuthority() (*CertificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("ecdsa.GenerateKey: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: "go-http-test CA", Organization: []string{"go-http-test"}},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("x509.CreateCertificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("x509.ParseCertificate: %w", err)
+	}
+
+	return &CertificateAuthority{
+		Certificate: cert,
+		PrivateKey:  key,
+	}, nil
+}
+
+// CertPool returns a pool containing the CA certificate, to be trusted by the clients.
+func (ca *CertificateAuthority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+
+	return pool
+}
+
+// CertificatePEM returns the CA certificate PEM encoded, e.g. to be trusted by non-Go clients.
+func (ca *CertificateAuthority) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate.Raw})
+}
+
+// IssueServerCertificate issues a server certificate valid for the hosts.
+// A host can be a DNS name or an IP address.
+func (ca *CertificateAuthority) IssueServerCertificate(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return tls.Certificate{}, fmt.Errorf("at least one host is required")
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"go-http-test"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	return ca.issue(template)
+}
+
+// IssueClientCertificate issues a client certificate for mutual TLS.
+// A SAN can be an IP address, an email address, a URI (e.g. a SPIFFE ID) or a DNS name.
+func (ca *CertificateAuthority) IssueClientCertificate(commonName string, sans ...string) (tls.Certificate, error) {
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"go-http-test"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if strings.Contains(san, "://") {
+			uri, err := url.Parse(san)
+			if err != nil {
+				return tls.Certificate{}, fmt.Errorf("url.Parse: %w", err)
+			}
+			template.URIs = append(template.URIs, uri)
+		} else if strings.Contains(san, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, san)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	return ca.issue(template)
+}
+
+// issue signs the template with the CA, using a newly generated key.
+func (ca *CertificateAuthority) issue(template *x509.Certificate) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("ecdsa.GenerateKey: %w", err)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, key.Public(), ca.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("x509.CreateCertificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("x509.ParseCertificate: %w", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.Certificate.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// getCertificate returns the server certificate for the host, issuing it if
+// needed, or if the cached one expires soon.
+// defaultHosts are used when the client does not send the server name (SNI),
+// e.g. when connecting to an IP address.
+func (ca *CertificateAuthority) getCertificate(host string, defaultHosts []string) (*tls.Certificate, error) {
+	hosts := defaultHosts
+	if host != "" {
+		hosts = append([]string{host}, defaultHosts...)
+	}
+	key := strings.Join(hosts, ",")
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if cert, ok := ca.leafs[key]; ok && time.Until(cert.Leaf.NotAfter) > certificateRenewal {
+		return cert, nil
+	}
+	if ca.leafs == nil {
+		ca.leafs = map[string]*tls.Certificate{}
+	}
+
+	cert, err := ca.IssueServerCertificate(hosts...)
+	if err != nil {
+		return nil, err
+	}
+	ca.leafs[key] = &cert
+
+	return &cert, nil
+}
+
+// newSerialNumber generates a random certificate serial number.
+func newSerialNumber() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+
+	return serial
+}
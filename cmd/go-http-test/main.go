@@ -0,0 +1,132 @@
+// Command go-http-test runs a go-http-test Server as a standalone mock server.
+// It loads the stubs from a mappings directory, so the same fixtures used in Go
+// tests can be used by non-Go services and integration environments.
+//
+// Usage:
+//
+//	go-http-test --port 8080 --mappings ./mappings [--watch] [--tls [--tls-cert cert.pem --tls-key key.pem] [--tls-ca-out ca.pem] [--mtls [--tls-client-ca client-ca.pem]]] [--http2] [--verbose] [--admin=false]
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+// options are the command line options.
+type options struct {
+	host     string
+	port     int
+	mappings string
+	watch    bool
+	tls      bool
+	certFile string
+	keyFile  string
+	caOut    string
+	mtls     bool
+	http2    bool
+	clientCA string
+	verbose  bool
+	admin    bool
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.host, "host", "0.0.0.0", "host to listen on")
+	flag.IntVar(&opts.port, "port", 8080, "port to listen on")
+	flag.StringVar(&opts.mappings, "mappings", "", "directory containing the *.json mapping files")
+	flag.BoolVar(&opts.watch, "watch", false, "reload the mappings when the files change")
+	flag.BoolVar(&opts.tls, "tls", false, "serve HTTPS instead of HTTP")
+	flag.StringVar(&opts.certFile, "tls-cert", "", "TLS certificate file, generated if not set")
+	flag.StringVar(&opts.keyFile, "tls-key", "", "TLS private key file, generated if not set")
+	flag.StringVar(&opts.caOut, "tls-ca-out", "", "file to write the generated CA certificate to, for the clients to trust")
+	flag.BoolVar(&opts.mtls, "mtls", false, "require and verify the client certificates")
+	flag.StringVar(&opts.clientCA, "tls-client-ca", "", "CA file to verify the client certificates, the generated CA if not set")
+	flag.BoolVar(&opts.http2, "http2", false, "enable HTTP/2, over TLS and cleartext (h2c)")
+	flag.BoolVar(&opts.verbose, "verbose", false, "log every request")
+	flag.BoolVar(&opts.admin, "admin", true, "serve the admin API under /__admin")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "go-http-test: ", log.LstdFlags)
+
+	if err := run(opts, logger); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(opts options, logger *log.Logger) error {
+	config := httptest.ServerConfig{
+		HTTP2:       opts.http2,
+		Logger:      logger,
+		Verbose:     opts.verbose,
+		EnableAdmin: opts.admin,
+	}
+
+	if opts.tls {
+		config.TLS = true
+		if opts.certFile != "" || opts.keyFile != "" {
+			cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+			if err != nil {
+				return fmt.Errorf("tls.LoadX509KeyPair: %w", err)
+			}
+			config.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+	}
+
+	if opts.mtls {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if opts.clientCA != "" {
+			b, err := os.ReadFile(opts.clientCA)
+			if err != nil {
+				return fmt.Errorf("read client CA: %w", err)
+			}
+			config.ClientCAs = x509.NewCertPool()
+			if !config.ClientCAs.AppendCertsFromPEM(b) {
+				return fmt.Errorf("no certificate found in %s", opts.clientCA)
+			}
+		}
+	}
+
+	address := fmt.Sprintf("%s:%d", opts.host, opts.port)
+	server, err := httptest.NewServer(address, config)
+	if err != nil {
+		return fmt.Errorf("httptest.NewServer: %w", err)
+	}
+	defer server.Close()
+
+	if opts.caOut != "" {
+		if server.CA() == nil {
+			return fmt.Errorf("--tls-ca-out requires the CA to be generated")
+		}
+		if err := os.WriteFile(opts.caOut, server.CA().CertificatePEM(), 0o644); err != nil {
+			return fmt.Errorf("write CA certificate: %w", err)
+		}
+	}
+
+	if opts.mappings != "" {
+		load := server.LoadMappings
+		if opts.watch {
+			load = server.WatchMappings
+		}
+		if err := load(opts.mappings); err != nil {
+			return fmt.Errorf("load mappings: %w", err)
+		}
+	}
+
+	logger.Printf("listening on %s", server.URL())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	logger.Printf("shutting down")
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package httptest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+// These tests are meant to be run with -race.
+
+func (s *serverTestSuite) TestConcurrent_RegisterWhileServing() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(body string) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(http.StatusOK)
+			_, _ = w.SetBodyBytes([]byte(body))
+		}
+	}
+	server.RegisterHandler(http.MethodGet, "/stable", respond("ok"))
+
+	const workers, requests = 8, 50
+	client := &http.Client{Timeout: 5 * time.Second}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				// Re-register the served path, and register new ones.
+				server.RegisterHandler(http.MethodGet, "/stable", respond("ok"))
+				server.RegisterHandler(http.MethodGet, fmt.Sprintf("/dynamic/%d/%d", i, j), respond("dynamic"))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				res, err := client.Get(baseURL + "/stable")
+				if !s.NoError(err) {
+					return
+				}
+				body, err := io.ReadAll(res.Body)
+				_ = res.Body.Close()
+				s.NoError(err)
+				s.Equal(http.StatusOK, res.StatusCode)
+				s.Equal("ok", string(body))
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(workers*requests, server.GetNCalls(http.MethodGet, "/stable"))
+	s.Len(server.GetCalls(http.MethodGet, "/stable"), workers*requests)
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, fmt.Sprintf("/dynamic/%d/%d", workers-1, requests-1), nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestConcurrent_JournalsWhileServing() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	register := func() {
+		server.RegisterHandler(http.MethodPost, "/path/:id", func(w httptest.ResponseWriter, r *httptest.Request) {
+			r.Header.Set("X-Modified", "true")
+			w.SetStatusCode(http.StatusOK)
+		})
+	}
+	register()
+
+	const workers, requests = 8, 50
+	client := &http.Client{Timeout: 5 * time.Second}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				res, err := client.Post(fmt.Sprintf("%s/path/%d", baseURL, i), "text/plain", nil)
+				if !s.NoError(err) {
+					return
+				}
+				_ = res.Body.Close()
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				for _, call := range server.GetCalls(http.MethodPost, "/path/:id") {
+					// The recorded headers are not modified by the handler.
+					s.Empty(call.Headers.Get("X-Modified"))
+				}
+				server.GetNCalls(http.MethodPost, "/path/:id")
+				server.GetAllCalls()
+				switch {
+				case i == 0 && j%10 == 0:
+					server.ResetAll()
+					register()
+				case i == 1 && j%5 == 0:
+					server.ResetCalls()
+				case i == 2 && j%5 == 0:
+					server.ResetNCalls()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	server.ResetCalls()
+	res, _, err := s.httpClient.Do(ctx, http.MethodPost, "/path/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal(1, server.GetNCalls(http.MethodPost, "/path/:id"))
+	s.Len(server.GetCalls(http.MethodPost, "/path/:id"), 1)
+}
+
+func (s *serverTestSuite) TestConcurrent_GetCallsReturnsCopy() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	_, _, err = s.httpClient.Do(ctx, http.MethodGet, "/path", nil, nil, nil)
+	s.NoError(err)
+
+	calls := server.GetCalls(http.MethodGet, "/path")
+	s.Len(calls, 1)
+	calls[0].Body = []byte("modified")
+
+	_, _, err = s.httpClient.Do(ctx, http.MethodGet, "/path", nil, nil, nil)
+	s.NoError(err)
+
+	calls = server.GetCalls(http.MethodGet, "/path")
+	s.Len(calls, 2)
+	s.Empty(calls[0].Body)
+}
@@ -0,0 +1,90 @@
+package httptest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DialContext returns a dial function connecting to the server for the
+// addresses of the hosts, like an /etc/hosts entry, and dialing the other
+// addresses normally. Use it as the http.Transport DialContext to test code
+// calling hardcoded URLs, see HostsTransport.
+//
+// A host is a host name and port, e.g. "slack.com:443", a host name matching
+// any port, e.g. "slack.com", or a "*." wildcard matching any subdomain, e.g.
+// "*.slack.com". All the addresses are dialed to the server if no host is given.
+// The server is dialed on its network, e.g. its Unix domain socket.
+// Dialing an in-memory server fails, use Server.Transport instead.
+func (s *Server) DialContext(hosts ...string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if len(hosts) == 0 || matchHosts(hosts, address) {
+			if s.listener == nil {
+				return nil, errInMemory
+			}
+			addr := s.listener.Addr()
+			return d.DialContext(ctx, addr.Network(), addr.String())
+		}
+
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// HostsTransport returns a transport sending the requests of the hosts to the
+// server, see DialContext, and trusting the server certificates, e.g.
+//
+//	server.Host("slack.com").RegisterHandler(http.MethodPost, "/api/chat.postMessage", handler)
+//	client := &http.Client{Transport: server.HostsTransport("slack.com")}
+//	client.Post("https://slack.com/api/chat.postMessage", ...)
+//
+// The https URLs require the server to serve HTTPS, e.g. with ServerConfig.TLS,
+// its certificates are then issued for the requested hosts.
+// The requests are routed to the virtual hosts of their host, see Server.Host.
+func (s *Server) HostsTransport(hosts ...string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The requests go to the server, whatever the proxy environment variables.
+	transport.Proxy = nil
+	transport.DialContext = s.DialContext(hosts...)
+	transport.TLSClientConfig = &tls.Config{RootCAs: s.CertPool()}
+	transport.ForceAttemptHTTP2 = s.config.HTTP2
+
+	return transport
+}
+
+// clientDialContext returns the dial function of the server clients: the
+// server Unix domain socket for all the addresses, or nil to dial normally.
+func (s *Server) clientDialContext() func(ctx context.Context, network, address string) (net.Conn, error) {
+	if !s.isUnix() {
+		return nil
+	}
+
+	return s.DialContext()
+}
+
+// matchHosts returns whether the address, e.g. "slack.com:443", matches one of the hosts.
+func matchHosts(hosts []string, address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(host)
+	address = strings.ToLower(address)
+
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		switch {
+		case h == address, h == host:
+			return true
+		case strings.HasPrefix(h, "*."):
+			if domain := h[1:]; strings.HasSuffix(host, domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package httptest_test
+
+import (
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestHostsTransport() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+	server.Host("slack.com").RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	client := &http.Client{Transport: server.HostsTransport("slack.com", "*.example.test", "api.test:8080")}
+	testCases := []struct {
+		url            string
+		expectedStatus int
+	}{
+		{"http://slack.com/path", http.StatusAccepted},
+		{"http://SLACK.com:8080/path", http.StatusAccepted},
+		{"http://a.b.example.test/path", http.StatusOK},
+		{"http://api.test:8080/path", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		res, err := client.Get(tc.url)
+		if !s.NoError(err, tc.url) {
+			continue
+		}
+		_ = res.Body.Close()
+		s.Equal(tc.expectedStatus, res.StatusCode, tc.url)
+	}
+
+	// The other addresses are dialed normally.
+	for _, url := range []string{"http://127.0.0.1:1/path", "http://api.test:1/path", "http://example.test:1/path"} {
+		_, err = client.Get(url)
+		s.Error(err, url)
+	}
+}
+
+func (s *serverTestSuite) TestHostsTransport_TLS() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{TLS: true, HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	// All the addresses are dialed to the server without hosts.
+	client := &http.Client{Transport: server.HostsTransport()}
+	res, err := client.Get("https://slack.com/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("HTTP/2.0", res.Proto)
+	s.Equal("slack.com", res.TLS.PeerCertificates[0].DNSNames[0])
+}
@@ -0,0 +1,168 @@
+package httptest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// supportedEncodings are the supported content encodings, by order of preference.
+var supportedEncodings = []string{"gzip", "br", "zstd", "deflate"}
+
+// zstdDecoder decodes the zstd bodies, DecodeAll is safe for concurrent use.
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+})
+
+// zstdEncoder encodes the zstd bodies, EncodeAll is safe for concurrent use.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// decodeBody decodes the body according to the Content-Encoding header of the request.
+// The body is returned as is if the request is not encoded.
+func decodeBody(header http.Header, body []byte) ([]byte, error) {
+	encodings := contentEncodings(header)
+	// The encodings are listed in the order they were applied.
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		body, err = decodeContentEncoding(encodings[i], body)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s body: %w", encodings[i], err)
+		}
+	}
+
+	return body, nil
+}
+
+// contentEncodings returns the content encodings of the request, identity excluded.
+func contentEncodings(header http.Header) []string {
+	var encodings []string
+	for _, v := range header.Values("Content-Encoding") {
+		for _, e := range strings.Split(v, ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+	}
+
+	return encodings
+}
+
+// decodeContentEncoding decodes a body encoded with gzip, deflate, br or zstd.
+func decodeContentEncoding(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case "deflate":
+		// deflate is the zlib format, but some clients send raw deflate.
+		r, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return io.ReadAll(flate.NewReader(bytes.NewReader(body)))
+		}
+		return io.ReadAll(r)
+	case "br":
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(body)))
+	case "zstd":
+		d, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return d.DecodeAll(body, nil)
+	}
+
+	return nil, fmt.Errorf("unsupported content encoding")
+}
+
+// DecodedBody reads the request body, decoded according to the Content-Encoding header.
+// The raw body is restored, so it can be read again.
+func (r *Request) DecodedBody() ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return decodeBody(r.Header, body)
+}
+
+// encodeContentEncoding encodes a body with gzip, deflate, br or zstd.
+func encodeContentEncoding(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		e, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return e.EncodeAll(body, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding")
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality
+// in the Accept-Encoding header values, or an empty string if none is accepted.
+func negotiateEncoding(acceptEncoding []string) string {
+	qualities := map[string]float64{}
+	for _, v := range acceptEncoding {
+		for _, e := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(e), ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+			qualities[strings.ToLower(strings.TrimSpace(name))] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supportedEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
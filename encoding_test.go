@@ -0,0 +1,317 @@
+package httptest_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+// compress compresses b with the writer returned by newWriter.
+func compress(b []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	_, _ = w.Write(b)
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+func gzipBytes(b []byte) []byte {
+	return compress(b, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+}
+
+func brotliBytes(b []byte) []byte {
+	return compress(b, func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) })
+}
+
+func (s *serverTestSuite) TestStoreCall_DecodesBody() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	path := "/some-path"
+	reqBody := []byte(`{"some":"body"}`)
+
+	testCases := []struct {
+		name              string
+		contentEncoding   string
+		rawBody           []byte
+		expectedBody      []byte
+		expectedBodyError string
+	}{
+		{
+			name:         "not encoded",
+			rawBody:      reqBody,
+			expectedBody: reqBody,
+		},
+		{
+			name:            "gzip",
+			contentEncoding: "gzip",
+			rawBody:         gzipBytes(reqBody),
+			expectedBody:    reqBody,
+		},
+		{
+			name:            "deflate",
+			contentEncoding: "deflate",
+			rawBody:         compress(reqBody, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+			expectedBody:    reqBody,
+		},
+		{
+			name:            "raw deflate",
+			contentEncoding: "deflate",
+			rawBody: compress(reqBody, func(w io.Writer) io.WriteCloser {
+				fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return fw
+			}),
+			expectedBody: reqBody,
+		},
+		{
+			name:            "br",
+			contentEncoding: "br",
+			rawBody:         brotliBytes(reqBody),
+			expectedBody:    reqBody,
+		},
+		{
+			name:            "zstd",
+			contentEncoding: "zstd",
+			rawBody: compress(reqBody, func(w io.Writer) io.WriteCloser {
+				zw, _ := zstd.NewWriter(w)
+				return zw
+			}),
+			expectedBody: reqBody,
+		},
+		{
+			name:            "multiple encodings",
+			contentEncoding: "gzip, br",
+			rawBody:         brotliBytes(gzipBytes(reqBody)),
+			expectedBody:    reqBody,
+		},
+		{
+			name:              "malformed",
+			contentEncoding:   "gzip",
+			rawBody:           []byte("not gzip"),
+			expectedBody:      []byte("not gzip"),
+			expectedBodyError: "decode gzip body: unexpected EOF",
+		},
+		{
+			name:              "unsupported",
+			contentEncoding:   "compress",
+			rawBody:           []byte("abcd"),
+			expectedBody:      []byte("abcd"),
+			expectedBodyError: "decode compress body: unsupported content encoding",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			server.ResetAll()
+			server.RegisterHandler(http.MethodPost, path, func(w httptest.ResponseWriter, r *httptest.Request) {
+				// The handler still gets the raw body.
+				body, err := io.ReadAll(r.Body)
+				s.NoError(err)
+				s.Equal(tc.rawBody, body)
+				w.SetStatusCode(http.StatusOK)
+			})
+
+			headers := map[string]string{"Content-Type": "application/json"}
+			if tc.contentEncoding != "" {
+				headers["Content-Encoding"] = tc.contentEncoding
+			}
+			_, _, err := s.httpClient.Do(ctx, http.MethodPost, path, headers, tc.rawBody, nil)
+			s.NoError(err)
+
+			call := server.GetCalls(http.MethodPost, path)[0]
+			s.Equal(tc.expectedBody, call.Body)
+			s.Equal(tc.expectedBodyError, call.BodyError)
+			if tc.contentEncoding == "" {
+				s.Nil(call.RawBody)
+			} else {
+				s.Equal(tc.rawBody, call.RawBody)
+			}
+		})
+	}
+}
+
+func (s *serverTestSuite) TestDecodedBody() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodPost, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		body, err := r.DecodedBody()
+		s.NoError(err)
+		s.Equal("hello", string(body))
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodPost, "/some-path", map[string]string{
+		"Content-Encoding": "br",
+	}, brotliBytes([]byte("hello")), nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestRegisterGraphQL_CompressedRequest() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGraphQL("/graphql", httptest.GraphQLMatch{OperationName: "GetUser"}, httptest.GraphQLResponse{
+		Data: map[string]any{"user": nil},
+	})
+
+	b, err := json.Marshal(httptest.GraphQLRequest{Query: `query GetUser { user { id } }`})
+	s.NoError(err)
+	res, body, err := s.httpClient.Do(ctx, http.MethodPost, "/graphql", map[string]string{
+		"Content-Type":     "application/json",
+		"Content-Encoding": "gzip",
+	}, gzipBytes(b), nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.JSONEq(`{"data": {"user": null}}`, string(body))
+}
+
+// decompress decodes a body encoded with gzip, deflate, br or zstd.
+func decompress(encoding string, b []byte) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(b))
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(b))
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(b))
+		r = d
+	default:
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func (s *serverTestSuite) TestResponseWriter_Compress() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	resBody := []byte(`{"some":"response"}`)
+
+	testCases := []struct {
+		name               string
+		compress           func(w *httptest.ResponseWriter)
+		acceptEncoding     string
+		expectedHeader     string
+		expectedCompressor string
+	}{
+		{
+			name:               "accepted with quality",
+			compress:           (*httptest.ResponseWriter).CompressAccepted,
+			acceptEncoding:     "gzip;q=0.5, br",
+			expectedHeader:     "br",
+			expectedCompressor: "br",
+		},
+		{
+			name:               "accepted zstd",
+			compress:           (*httptest.ResponseWriter).CompressAccepted,
+			acceptEncoding:     "zstd",
+			expectedHeader:     "zstd",
+			expectedCompressor: "zstd",
+		},
+		{
+			name:               "accepted any",
+			compress:           (*httptest.ResponseWriter).CompressAccepted,
+			acceptEncoding:     "*",
+			expectedHeader:     "gzip",
+			expectedCompressor: "gzip",
+		},
+		{
+			name:           "none accepted",
+			compress:       (*httptest.ResponseWriter).CompressAccepted,
+			acceptEncoding: "identity, gzip;q=0",
+		},
+		{
+			name:               "forced",
+			compress:           func(w *httptest.ResponseWriter) { w.Compress("deflate") },
+			expectedHeader:     "deflate",
+			expectedCompressor: "deflate",
+		},
+		{
+			name: "wrong header",
+			compress: func(w *httptest.ResponseWriter) {
+				w.Header().Set("Content-Encoding", "br")
+				w.Compress("gzip")
+			},
+			acceptEncoding:     "br",
+			expectedHeader:     "br",
+			expectedCompressor: "gzip",
+		},
+	}
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+				tc.compress(&w)
+				_, err := w.SetBodyJSON(json.RawMessage(resBody))
+				s.NoError(err)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, baseURL+"/some-path", nil)
+			s.NoError(err)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			res, err := client.Do(req)
+			s.NoError(err)
+			body, err := io.ReadAll(res.Body)
+			s.NoError(err)
+			s.NoError(res.Body.Close())
+
+			s.Equal(tc.expectedHeader, res.Header.Get("Content-Encoding"))
+			s.Equal("application/json", res.Header.Get("Content-Type"))
+			decoded, err := decompress(tc.expectedCompressor, body)
+			s.NoError(err)
+			s.Equal(resBody, decoded)
+		})
+	}
+}
+
+func (s *serverTestSuite) TestRegisterMapping_Encoding() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/some-path"},
+		Response: httptest.MappingResponse{Body: "hello", Encoding: httptest.EncodingAccepted},
+	})
+	s.NoError(err)
+
+	// The default client accepts gzip, and decodes it transparently.
+	res, body, err := s.httpClient.Do(ctx, http.MethodGet, "/some-path", nil, nil, nil)
+	s.NoError(err)
+	s.True(res.Uncompressed)
+	s.Equal("hello", string(body))
+
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/some-path"},
+		Response: httptest.MappingResponse{Encoding: "compress"},
+	})
+	s.Error(err)
+}
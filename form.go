@@ -0,0 +1,126 @@
+package httptest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+)
+
+// FormPart is a part of a multipart/form-data body.
+type FormPart struct {
+	// Name is the form field name.
+	Name string `json:"name"`
+	// Filename is the name of the uploaded file, empty for a form value.
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     []byte `json:"content"`
+}
+
+// PartMatcher matches a FormPart. The empty fields match any part.
+type PartMatcher struct {
+	Name        string
+	Filename    string
+	ContentType string
+	// Content matches the exact content.
+	Content []byte
+	// ContentContains matches a part whose content contains it.
+	ContentContains []byte
+}
+
+// Matches returns whether the part matches.
+func (m PartMatcher) Matches(p FormPart) bool {
+	return (m.Name == "" || m.Name == p.Name) &&
+		(m.Filename == "" || m.Filename == p.Filename) &&
+		(m.ContentType == "" || m.ContentType == p.ContentType) &&
+		(m.Content == nil || bytes.Equal(m.Content, p.Content)) &&
+		(m.ContentContains == nil || bytes.Contains(p.Content, m.ContentContains))
+}
+
+// FormValue returns the first value of the form field, or an empty string.
+func (r RequestMade) FormValue(name string) string {
+	return r.Form.Get(name)
+}
+
+// File returns the first file uploaded with the form field name.
+func (r RequestMade) File(name string) (FormPart, bool) {
+	for _, p := range r.Parts {
+		if p.Name == name && p.Filename != "" {
+			return p, true
+		}
+	}
+
+	return FormPart{}, false
+}
+
+// MatchPart returns the first multipart part matching m.
+func (r RequestMade) MatchPart(m PartMatcher) (FormPart, bool) {
+	for _, p := range r.Parts {
+		if m.Matches(p) {
+			return p, true
+		}
+	}
+
+	return FormPart{}, false
+}
+
+// parseForm parses an application/x-www-form-urlencoded or a multipart/form-data
+// body. The form values of a multipart body are also returned in form.
+// It returns nils for the other content types, or a malformed body.
+func parseForm(contentType string, body []byte) (form url.Values, parts []FormPart) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		form, err = url.ParseQuery(string(body))
+		if err != nil {
+			return nil, nil
+		}
+		return form, nil
+	case "multipart/form-data":
+		return parseMultipartForm(body, params["boundary"])
+	}
+
+	return nil, nil
+}
+
+func parseMultipartForm(body []byte, boundary string) (url.Values, []FormPart) {
+	if boundary == "" {
+		return nil, nil
+	}
+
+	form := url.Values{}
+	var parts []FormPart
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		p, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil
+		}
+
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return nil, nil
+		}
+		part := FormPart{
+			Name:        p.FormName(),
+			Filename:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Content:     content,
+		}
+		parts = append(parts, part)
+		if part.Filename == "" {
+			form.Add(part.Name, string(content))
+		}
+	}
+
+	return form, parts
+}
@@ -0,0 +1,111 @@
+package httptest_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestStoreCall_MultipartForm() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodPost, "/upload", func(w httptest.ResponseWriter, r *httptest.Request) {
+		// The body is still readable by the handler.
+		s.NoError(r.ParseMultipartForm(1 << 20))
+		s.Equal("avatar", r.FormValue("kind"))
+		w.SetStatusCode(http.StatusCreated)
+	})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	s.NoError(writer.WriteField("kind", "avatar"))
+	s.NoError(writer.WriteField("tag", "a"))
+	s.NoError(writer.WriteField("tag", "b"))
+	file, err := writer.CreateFormFile("file", "me.png")
+	s.NoError(err)
+	_, err = file.Write([]byte("\x89PNG fake image"))
+	s.NoError(err)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="metadata"; filename="meta.json"`)
+	header.Set("Content-Type", "application/json")
+	meta, err := writer.CreatePart(header)
+	s.NoError(err)
+	_, err = meta.Write([]byte(`{"width": 100}`))
+	s.NoError(err)
+	s.NoError(writer.Close())
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodPost, "/upload", map[string]string{
+		"Content-Type": writer.FormDataContentType(),
+	}, body.Bytes(), nil)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, res.StatusCode)
+
+	call := server.GetCalls(http.MethodPost, "/upload")[0]
+	s.Equal(url.Values{"kind": {"avatar"}, "tag": {"a", "b"}}, call.Form)
+	s.Equal("avatar", call.FormValue("kind"))
+	s.Equal(5, len(call.Parts))
+
+	file1, ok := call.File("file")
+	s.True(ok)
+	s.Equal(httptest.FormPart{
+		Name:        "file",
+		Filename:    "me.png",
+		ContentType: "application/octet-stream",
+		Content:     []byte("\x89PNG fake image"),
+	}, file1)
+
+	_, ok = call.File("kind")
+	s.False(ok)
+
+	part, ok := call.MatchPart(httptest.PartMatcher{ContentType: "application/json", ContentContains: []byte(`"width"`)})
+	s.True(ok)
+	s.Equal("meta.json", part.Filename)
+
+	_, ok = call.MatchPart(httptest.PartMatcher{Name: "file", Content: []byte("other")})
+	s.False(ok)
+}
+
+func (s *serverTestSuite) TestStoreCall_URLEncodedForm() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodPost, "/login", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	form := url.Values{"username": {"abcd"}, "scope": {"read", "write"}}
+	_, _, err = s.httpClient.Do(ctx, http.MethodPost, "/login", map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	}, []byte(form.Encode()), nil)
+	s.NoError(err)
+
+	call := server.GetCalls(http.MethodPost, "/login")[0]
+	s.Equal(form, call.Form)
+	s.Empty(call.Parts)
+}
+
+func (s *serverTestSuite) TestStoreCall_NotAForm() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodPost, "/json", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	_, _, err = s.httpClient.Do(ctx, http.MethodPost, "/json", map[string]string{
+		"Content-Type": "application/json",
+	}, []byte(`{"a": 1}`), nil)
+	s.NoError(err)
+
+	call := server.GetCalls(http.MethodPost, "/json")[0]
+	s.Nil(call.Form)
+	s.Nil(call.Parts)
+}
@@ -0,0 +1,229 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// GraphQLRequest is a GraphQL request, as sent in the body of a POST request.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLCall is a GraphQL request made to the server.
+type GraphQLCall struct {
+	// Path is the path of the GraphQL endpoint, e.g. "/graphql".
+	Path string `json:"path"`
+	GraphQLRequest
+	Headers http.Header `json:"headers"`
+}
+
+// GraphQLMatch describes which GraphQL requests a stub handles.
+// The empty fields match any request.
+type GraphQLMatch struct {
+	// OperationName matches the operation name, from the operationName field
+	// or from the query.
+	OperationName string
+	// Query matches the query, ignoring the whitespaces, commas and comments.
+	Query string
+	// Variables matches the request variables with these values, other variables are ignored.
+	Variables map[string]any
+}
+
+// GraphQLResponse is the response of a GraphQL stub.
+type GraphQLResponse struct {
+	// Status defaults to 200 if not set.
+	Status int
+	// Data is marshalled to JSON as the data field.
+	Data any
+	// Errors is the errors field.
+	Errors []GraphQLError
+}
+
+// GraphQLError is an error of a GraphQL response.
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// graphQLStub is a registered GraphQL stub.
+type graphQLStub struct {
+	match    GraphQLMatch
+	response GraphQLResponse
+}
+
+// graphQLOperationName finds the name of the first operation of a query.
+var graphQLOperationName = regexp.MustCompile(`\b(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// graphQLComment matches a comment of a query.
+var graphQLComment = regexp.MustCompile(`#[^\n\r]*`)
+
+// graphQLIgnored matches the whitespaces and commas of a query, which are insignificant.
+var graphQLIgnored = regexp.MustCompile(`[\s,]+`)
+
+// graphQLPunctuator matches a punctuator with the spaces around it.
+var graphQLPunctuator = regexp.MustCompile(` ?([!$&():=@\[\]{|}]) ?`)
+
+// RegisterGraphQL registers a GraphQL stub on a path, e.g. "/graphql".
+// Several stubs can be registered on the same path, the latest registered
+// matching stub responds. The requests matching no stub get a 404 with a
+// GraphQL error.
+//
+// The calls are recorded like the other calls, as POST requests on the path,
+// and per operation, see Server.GetGraphQLCalls.
+func (s *Server) RegisterGraphQL(path string, match GraphQLMatch, response GraphQLResponse) {
+	s.mu.Lock()
+	s.graphQLStubs[path] = append(s.graphQLStubs[path], graphQLStub{match: match, response: response})
+	s.mu.Unlock()
+
+	s.RegisterHandler(http.MethodPost, path, s.graphQLHandler(path))
+}
+
+// graphQLHandler returns the handler that dispatches the GraphQL requests of a path to the matching stub.
+func (s *Server) graphQLHandler(path string) ServerHandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		var req GraphQLRequest
+		body, err := r.DecodedBody()
+		if err == nil {
+			err = json.Unmarshal(body, &req)
+		}
+		if err != nil {
+			writeGraphQLResponse(w, GraphQLResponse{
+				Status: http.StatusBadRequest,
+				Errors: []GraphQLError{{Message: fmt.Sprintf("invalid GraphQL request: %v", err)}},
+			})
+			return
+		}
+		if req.OperationName == "" {
+			req.OperationName = parseGraphQLOperationName(req.Query)
+		}
+
+		s.mu.Lock()
+		s.graphQLCalls[req.OperationName] = append(s.graphQLCalls[req.OperationName], GraphQLCall{
+			Path:           path,
+			GraphQLRequest: req,
+			Headers:        r.Header.Clone(),
+		})
+		stub, ok := s.matchGraphQL(path, req)
+		s.mu.Unlock()
+
+		if !ok {
+			writeGraphQLResponse(w, GraphQLResponse{
+				Status: http.StatusNotFound,
+				Errors: []GraphQLError{{Message: fmt.Sprintf("no GraphQL stub matched operation %q", req.OperationName)}},
+			})
+			return
+		}
+
+		writeGraphQLResponse(w, stub.response)
+	}
+}
+
+// matchGraphQL finds the latest registered stub of the path matching the request.
+// The caller must hold s.mu.
+func (s *Server) matchGraphQL(path string, req GraphQLRequest) (graphQLStub, bool) {
+	stubs := s.graphQLStubs[path]
+	for i := len(stubs) - 1; i >= 0; i-- {
+		if stubs[i].match.matches(req) {
+			return stubs[i], true
+		}
+	}
+
+	return graphQLStub{}, false
+}
+
+// matches returns whether the request matches.
+func (m GraphQLMatch) matches(req GraphQLRequest) bool {
+	if m.OperationName != "" && m.OperationName != req.OperationName {
+		return false
+	}
+	if m.Query != "" && normalizeGraphQLQuery(m.Query) != normalizeGraphQLQuery(req.Query) {
+		return false
+	}
+	for k, v := range m.Variables {
+		actual, ok := req.Variables[k]
+		if !ok || !reflect.DeepEqual(normalizeJSON(v), actual) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeGraphQLResponse writes the response as a GraphQL JSON payload.
+func writeGraphQLResponse(w ResponseWriter, res GraphQLResponse) {
+	body := map[string]any{}
+	if res.Data != nil {
+		body["data"] = res.Data
+	}
+	if len(res.Errors) > 0 {
+		body["errors"] = res.Errors
+	}
+
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.SetStatusCode(status)
+	_, _ = w.SetBodyJSON(body)
+}
+
+// GetGraphQLCalls returns the GraphQL calls of an operation, in order.
+// Anonymous operations are recorded with an empty name.
+func (s *Server) GetGraphQLCalls(operationName string) []GraphQLCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]GraphQLCall(nil), s.graphQLCalls[operationName]...)
+}
+
+// GetGraphQLNCalls returns the number of GraphQL calls of an operation.
+func (s *Server) GetGraphQLNCalls(operationName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.graphQLCalls[operationName])
+}
+
+// parseGraphQLOperationName returns the name of the first operation of the query,
+// or an empty string if it is anonymous.
+func parseGraphQLOperationName(query string) string {
+	m := graphQLOperationName.FindStringSubmatch(graphQLComment.ReplaceAllString(query, ""))
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+// normalizeGraphQLQuery removes the insignificant characters of a query, so
+// queries with the same shape are equal.
+func normalizeGraphQLQuery(query string) string {
+	query = graphQLComment.ReplaceAllString(query, "")
+	query = graphQLIgnored.ReplaceAllString(query, " ")
+	query = graphQLPunctuator.ReplaceAllString(query, "$1")
+
+	return strings.TrimSpace(query)
+}
+
+// normalizeJSON converts v to the types decoded by encoding/json, e.g. int to float64.
+func normalizeJSON(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return v
+	}
+
+	return normalized
+}
@@ -0,0 +1,125 @@
+package httptest_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) postGraphQL(req httptest.GraphQLRequest) (int, map[string]any) {
+	b, err := json.Marshal(req)
+	s.NoError(err)
+
+	res, body, err := s.httpClient.Do(ctx, http.MethodPost, "/graphql", map[string]string{
+		"Content-Type": "application/json",
+	}, b, nil)
+	s.NoError(err)
+
+	var payload map[string]any
+	s.NoError(json.Unmarshal(body, &payload))
+
+	return res.StatusCode, payload
+}
+
+func (s *serverTestSuite) TestRegisterGraphQL() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGraphQL("/graphql", httptest.GraphQLMatch{OperationName: "GetUser"}, httptest.GraphQLResponse{
+		Data: map[string]any{"user": map[string]any{"id": "1", "name": "default"}},
+	})
+	server.RegisterGraphQL("/graphql", httptest.GraphQLMatch{
+		OperationName: "GetUser",
+		Variables:     map[string]any{"id": 2},
+	}, httptest.GraphQLResponse{
+		Data: map[string]any{"user": map[string]any{"id": "2", "name": "second"}},
+	})
+	server.RegisterGraphQL("/graphql", httptest.GraphQLMatch{
+		Query: `mutation { deleteUser(id: 1) { id } }`,
+	}, httptest.GraphQLResponse{
+		Errors: []httptest.GraphQLError{{Message: "forbidden", Path: []any{"deleteUser"}, Extensions: map[string]any{"code": "FORBIDDEN"}}},
+	})
+
+	testCases := []struct {
+		name           string
+		req            httptest.GraphQLRequest
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name: "operation name from the query",
+			req: httptest.GraphQLRequest{
+				Query:     `query GetUser($id: ID!) { user(id: $id) { id name } }`,
+				Variables: map[string]any{"id": 1},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]any{"data": map[string]any{"user": map[string]any{"id": "1", "name": "default"}}},
+		},
+		{
+			name: "variables",
+			req: httptest.GraphQLRequest{
+				Query:         `query GetUser($id: ID!) { user(id: $id) { id name } }`,
+				OperationName: "GetUser",
+				Variables:     map[string]any{"id": 2, "other": true},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]any{"data": map[string]any{"user": map[string]any{"id": "2", "name": "second"}}},
+		},
+		{
+			name: "query shape",
+			req: httptest.GraphQLRequest{
+				Query: "# delete the user\nmutation {\n  deleteUser(id: 1) {\n    id,\n  }\n}\n",
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]any{"errors": []any{map[string]any{
+				"message":    "forbidden",
+				"path":       []any{"deleteUser"},
+				"extensions": map[string]any{"code": "FORBIDDEN"},
+			}}},
+		},
+		{
+			name: "no match",
+			req: httptest.GraphQLRequest{
+				Query: `query ListUsers { users { id } }`,
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody: map[string]any{"errors": []any{map[string]any{
+				"message": `no GraphQL stub matched operation "ListUsers"`,
+			}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			status, body := s.postGraphQL(tc.req)
+			s.Equal(tc.expectedStatus, status)
+			s.Equal(tc.expectedBody, body)
+		})
+	}
+
+	s.Equal(4, server.GetNCalls(http.MethodPost, "/graphql"))
+	s.Equal(2, server.GetGraphQLNCalls("GetUser"))
+	s.Equal(1, server.GetGraphQLNCalls(""))
+	s.Equal(1, server.GetGraphQLNCalls("ListUsers"))
+
+	calls := server.GetGraphQLCalls("GetUser")
+	s.Equal("/graphql", calls[1].Path)
+	s.Equal(map[string]any{"id": float64(2), "other": true}, calls[1].Variables)
+
+	server.ResetCalls()
+	s.Equal(0, server.GetGraphQLNCalls("GetUser"))
+}
+
+func (s *serverTestSuite) TestRegisterGraphQL_InvalidRequest() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGraphQL("/graphql", httptest.GraphQLMatch{}, httptest.GraphQLResponse{Data: map[string]any{}})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodPost, "/graphql", nil, []byte("not json"), nil)
+	s.NoError(err)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
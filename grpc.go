@@ -0,0 +1,258 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcMessageHeaderLen is the length of the prefix of a gRPC message:
+// a compressed flag, and the length of the message.
+const grpcMessageHeaderLen = 5
+
+// grpcWebTrailerFlag flags the frame containing the trailers in gRPC-Web.
+const grpcWebTrailerFlag = 0x80
+
+// GRPCHandlerFunc is the interface of the gRPC method handler function.
+// The returned error is the status of the call, e.g. status.Error(codes.NotFound, "not found").
+// An error that is not a status is returned with codes.Unknown.
+type GRPCHandlerFunc func(stream *GRPCStream) error
+
+// GRPCStream is a gRPC call, to receive the request messages and send the response messages.
+type GRPCStream struct {
+	w       ResponseWriter
+	r       *Request
+	web     bool
+	text    bool
+	request [][]byte
+}
+
+// Request returns the HTTP request of the call, e.g. to read the metadata in the headers.
+func (s *GRPCStream) Request() *Request {
+	return s.r
+}
+
+// Header returns the response headers, sent as metadata with the first message.
+func (s *GRPCStream) Header() http.Header {
+	return s.w.Header()
+}
+
+// Receive decodes the next request message into m.
+// It returns io.EOF when there is no more message.
+func (s *GRPCStream) Receive(m proto.Message) error {
+	if len(s.request) == 0 {
+		return io.EOF
+	}
+	b := s.request[0]
+	s.request = s.request[1:]
+
+	if err := proto.Unmarshal(b, m); err != nil {
+		return fmt.Errorf("proto.Unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// Send sends a response message. A unary method sends one message,
+// a server-streaming method can send any number of messages.
+func (s *GRPCStream) Send(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("proto.Marshal: %w", err)
+	}
+
+	return s.write(0, b)
+}
+
+// write writes a length-prefixed frame, and flushes it.
+func (s *GRPCStream) write(flag byte, b []byte) error {
+	frame := make([]byte, grpcMessageHeaderLen+len(b))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:grpcMessageHeaderLen], uint32(len(b)))
+	copy(frame[grpcMessageHeaderLen:], b)
+
+	if s.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := s.w.SetBodyBytes(frame); err != nil {
+		return err
+	}
+	if f, ok := s.w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// finish sends the status of the call, in the trailers for gRPC, and in a
+// trailer frame for gRPC-Web.
+func (s *GRPCStream) finish(err error) {
+	st := status.Convert(err)
+
+	trailers := [][2]string{
+		{"grpc-status", strconv.Itoa(int(st.Code()))},
+		{"grpc-message", encodeGRPCMessage(st.Message())},
+	}
+
+	if s.web {
+		var b bytes.Buffer
+		for _, t := range trailers {
+			fmt.Fprintf(&b, "%s: %s\r\n", t[0], t[1])
+		}
+		_ = s.write(grpcWebTrailerFlag, b.Bytes())
+		return
+	}
+
+	for _, t := range trailers {
+		s.w.Header().Set(http.TrailerPrefix+t[0], t[1])
+	}
+}
+
+// RegisterGRPC registers a gRPC method handler, over gRPC and gRPC-Web.
+// fullMethod is the method path, e.g. "/package.Service/Method".
+// gRPC requires HTTP/2, see ServerConfig.HTTP2, gRPC-Web is served over HTTP/1.1 too.
+//
+// The calls are recorded like the other calls, as POST requests on fullMethod,
+// see RequestMade.GRPCMessages and RequestMade.DecodeGRPC to read the request messages.
+// Registering same method twice will overwrite the previous handler.
+func (s *Server) RegisterGRPC(fullMethod string, handler GRPCHandlerFunc) {
+	s.RegisterHandler(http.MethodPost, fullMethod, func(w ResponseWriter, r *Request) {
+		contentType := r.Header.Get("Content-Type")
+		if !isGRPCContentType(contentType) {
+			w.SetStatusCode(http.StatusUnsupportedMediaType)
+			_, _ = w.SetBodyBytes([]byte("unsupported content type " + contentType))
+			return
+		}
+
+		stream := &GRPCStream{
+			w:    w,
+			r:    r,
+			web:  strings.HasPrefix(contentType, "application/grpc-web"),
+			text: strings.HasPrefix(contentType, "application/grpc-web-text"),
+		}
+		if stream.web {
+			w.Header().Set("Content-Type", contentType)
+		} else {
+			w.Header().Set("Content-Type", "application/grpc")
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		messages, err := decodeGRPCMessages(body, stream.text)
+		if err != nil {
+			w.SetStatusCode(http.StatusOK)
+			stream.finish(status.Error(codes.Internal, err.Error()))
+			return
+		}
+		stream.request = messages
+
+		w.SetStatusCode(http.StatusOK)
+		stream.finish(handler(stream))
+	})
+}
+
+// GRPCResponse is a stub response of a gRPC method.
+type GRPCResponse struct {
+	// Messages are the response messages: one for a unary method, any number
+	// for a server-streaming method.
+	Messages []proto.Message
+	// Status is the status of the call, OK if nil.
+	Status *status.Status
+}
+
+// Handler returns the GRPCHandlerFunc that sends the response.
+func (res GRPCResponse) Handler() GRPCHandlerFunc {
+	return func(stream *GRPCStream) error {
+		for _, m := range res.Messages {
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+		}
+
+		return res.Status.Err()
+	}
+}
+
+// GRPCMessages returns the request messages of a gRPC call, still encoded.
+func (r RequestMade) GRPCMessages() ([][]byte, error) {
+	text := strings.HasPrefix(r.Headers.Get("Content-Type"), "application/grpc-web-text")
+
+	return decodeGRPCMessages(r.Body, text)
+}
+
+// DecodeGRPC decodes the first request message of a gRPC call into m.
+func (r RequestMade) DecodeGRPC(m proto.Message) error {
+	messages, err := r.GRPCMessages()
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("no gRPC message")
+	}
+	if err := proto.Unmarshal(messages[0], m); err != nil {
+		return fmt.Errorf("proto.Unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// isGRPCContentType returns whether the content type is gRPC or gRPC-Web,
+// e.g. "application/grpc", "application/grpc+proto" or "application/grpc-web-text".
+func isGRPCContentType(contentType string) bool {
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+// decodeGRPCMessages splits the length-prefixed messages of a request body.
+// Compressed messages are not supported.
+func decodeGRPCMessages(body []byte, text bool) ([][]byte, error) {
+	if text {
+		decoded, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("base64.DecodeString: %w", err)
+		}
+		body = decoded
+	}
+
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < grpcMessageHeaderLen {
+			return nil, fmt.Errorf("truncated gRPC message")
+		}
+		if body[0] != 0 {
+			return nil, fmt.Errorf("compressed gRPC messages are not supported")
+		}
+		n := int(binary.BigEndian.Uint32(body[1:grpcMessageHeaderLen]))
+		if len(body) < grpcMessageHeaderLen+n {
+			return nil, fmt.Errorf("truncated gRPC message")
+		}
+		messages = append(messages, body[grpcMessageHeaderLen:grpcMessageHeaderLen+n])
+		body = body[grpcMessageHeaderLen+n:]
+	}
+
+	return messages, nil
+}
+
+// encodeGRPCMessage percent-encodes the status message, as required by the gRPC protocol.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,202 @@
+package httptest_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) newGRPCClient() *grpc.ClientConn {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.NoError(err)
+
+	return conn
+}
+
+func (s *serverTestSuite) TestRegisterGRPC_Unary() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGRPC("/test.Greeter/Greet", func(stream *httptest.GRPCStream) error {
+		var req wrapperspb.StringValue
+		if err := stream.Receive(&req); err != nil {
+			return err
+		}
+		s.Equal([]string{"abc"}, stream.Request().Header.Values("x-request-id"))
+		return stream.Send(wrapperspb.String("hello " + req.Value))
+	})
+
+	conn := s.newGRPCClient()
+	defer conn.Close()
+
+	var res wrapperspb.StringValue
+	reqCtx := metadata.AppendToOutgoingContext(ctx, "x-request-id", "abc")
+	s.NoError(conn.Invoke(reqCtx, "/test.Greeter/Greet", wrapperspb.String("world"), &res))
+	s.Equal("hello world", res.Value)
+
+	calls := server.GetCalls(http.MethodPost, "/test.Greeter/Greet")
+	s.Equal(1, len(calls))
+	s.Equal("HTTP/2.0", calls[0].Proto)
+	var req wrapperspb.StringValue
+	s.NoError(calls[0].DecodeGRPC(&req))
+	s.Equal("world", req.Value)
+}
+
+func (s *serverTestSuite) TestRegisterGRPC_Status() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGRPC("/test.Greeter/Greet", httptest.GRPCResponse{
+		Status: status.New(codes.NotFound, "user not found: 100%"),
+	}.Handler())
+
+	conn := s.newGRPCClient()
+	defer conn.Close()
+
+	var res wrapperspb.StringValue
+	err = conn.Invoke(ctx, "/test.Greeter/Greet", wrapperspb.String("world"), &res)
+	st, ok := status.FromError(err)
+	s.True(ok)
+	s.Equal(codes.NotFound, st.Code())
+	s.Equal("user not found: 100%", st.Message())
+}
+
+func (s *serverTestSuite) TestRegisterGRPC_ServerStreaming() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGRPC("/test.Greeter/GreetMany", httptest.GRPCResponse{
+		Messages: []proto.Message{wrapperspb.String("one"), wrapperspb.String("two"), wrapperspb.String("three")},
+		Status:   status.New(codes.ResourceExhausted, "no more"),
+	}.Handler())
+
+	conn := s.newGRPCClient()
+	defer conn.Close()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/test.Greeter/GreetMany")
+	s.NoError(err)
+	s.NoError(stream.SendMsg(wrapperspb.String("world")))
+	s.NoError(stream.CloseSend())
+
+	var received []string
+	for {
+		var res wrapperspb.StringValue
+		if err = stream.RecvMsg(&res); err != nil {
+			break
+		}
+		received = append(received, res.Value)
+	}
+	s.Equal([]string{"one", "two", "three"}, received)
+	s.Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *serverTestSuite) TestRegisterGRPC_Web() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGRPC("/test.Greeter/Greet", httptest.GRPCResponse{
+		Messages: []proto.Message{wrapperspb.String("hello")},
+	}.Handler())
+
+	testCases := []struct {
+		name        string
+		contentType string
+		encode      func([]byte) []byte
+		decode      func([]byte) []byte
+	}{
+		{
+			name:        "binary",
+			contentType: "application/grpc-web+proto",
+			encode:      func(b []byte) []byte { return b },
+			decode:      func(b []byte) []byte { return b },
+		},
+		{
+			name:        "text",
+			contentType: "application/grpc-web-text",
+			encode:      func(b []byte) []byte { return []byte(base64.StdEncoding.EncodeToString(b)) },
+			decode:      decodeGRPCWebText,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			server.ResetCalls()
+
+			msg, err := proto.Marshal(wrapperspb.String("world"))
+			s.NoError(err)
+
+			res, body, err := s.httpClient.Do(ctx, http.MethodPost, "/test.Greeter/Greet", map[string]string{
+				"Content-Type": tc.contentType,
+			}, tc.encode(grpcFrame(0, msg)), nil)
+			s.NoError(err)
+			s.Equal(http.StatusOK, res.StatusCode)
+			s.Equal(tc.contentType, res.Header.Get("Content-Type"))
+
+			body = tc.decode(body)
+			expectedMsg, err := proto.Marshal(wrapperspb.String("hello"))
+			s.NoError(err)
+			s.Equal(append(grpcFrame(0, expectedMsg), grpcFrame(0x80, []byte("grpc-status: 0\r\ngrpc-message: \r\n"))...), body)
+
+			var req wrapperspb.StringValue
+			s.NoError(server.GetCalls(http.MethodPost, "/test.Greeter/Greet")[0].DecodeGRPC(&req))
+			s.Equal("world", req.Value)
+		})
+	}
+}
+
+func (s *serverTestSuite) TestRegisterGRPC_UnsupportedContentType() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterGRPC("/test.Greeter/Greet", httptest.GRPCResponse{}.Handler())
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodPost, "/test.Greeter/Greet", map[string]string{
+		"Content-Type": "application/json",
+	}, []byte("{}"), nil)
+	s.NoError(err)
+	s.Equal(http.StatusUnsupportedMediaType, res.StatusCode)
+}
+
+// grpcFrame returns a length-prefixed gRPC frame.
+func grpcFrame(flag byte, b []byte) []byte {
+	frame := []byte{flag, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+
+	return append(frame, b...)
+}
+
+// decodeGRPCWebText decodes a gRPC-Web text body, made of base64 chunks that can be padded.
+func decodeGRPCWebText(b []byte) []byte {
+	var out []byte
+	for len(b) > 0 {
+		// A chunk ends after its padding, or at the end of the body.
+		end := len(b)
+		if i := bytes.IndexByte(b, '='); i >= 0 {
+			end = i
+			for end < len(b) && b[end] == '=' {
+				end++
+			}
+		}
+		chunk, _ := base64.StdEncoding.DecodeString(string(b[:end]))
+		out = append(out, chunk...)
+		b = b[end:]
+	}
+
+	return out
+}
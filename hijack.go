@@ -0,0 +1,81 @@
+package httptest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// trackHijacked stores a hijacked connection to close it with the server, as
+// http.Server.Close does not close them, e.g. the WebSocket connections.
+// It returns the connection untracked once closed, or false if the server is
+// closed.
+func (s *Server) trackHijacked(conn net.Conn) (net.Conn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hijacked == nil {
+		return nil, false
+	}
+	s.hijacked[conn] = struct{}{}
+
+	return &trackedConn{Conn: conn, server: s}, true
+}
+
+func (s *Server) untrackHijacked(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.hijacked, conn)
+}
+
+// closeHijacked closes the hijacked connections. The connections hijacked
+// afterwards are refused.
+func (s *Server) closeHijacked() {
+	s.mu.Lock()
+	hijacked := s.hijacked
+	s.hijacked = nil
+	s.mu.Unlock()
+
+	for conn := range hijacked {
+		_ = conn.Close()
+	}
+}
+
+// hijackTracker is a ResponseWriter whose hijacked connection is tracked by
+// the server, for the handlers hijacking it themselves, e.g. the h2c upgrade.
+type hijackTracker struct {
+	http.ResponseWriter
+	server *Server
+}
+
+func (w hijackTracker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	tracked, ok := w.server.trackHijacked(conn)
+	if !ok {
+		_ = conn.Close()
+		return nil, nil, errors.New("httptest: the server is closed")
+	}
+
+	return tracked, rw, nil
+}
+
+// trackedConn is a hijacked connection, untracked when closed.
+type trackedConn struct {
+	net.Conn
+	server    *Server
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.server.untrackHijacked(c.Conn)
+	})
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,76 @@
+package httptest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// configureHTTP2 enables HTTP/2 on the http server.
+// Over TLS, it is negotiated with ALPN, see newTLSConfig.
+// Over cleartext, it is served by the handler, see handler.
+func (s *Server) configureHTTP2() error {
+	s.h2Server = &http2.Server{}
+	if err := http2.ConfigureServer(s.httpServer, s.h2Server); err != nil {
+		return fmt.Errorf("http2.ConfigureServer: %w", err)
+	}
+	// Serve the connections ourselves to inject the HTTP/2 faults.
+	s.httpServer.TLSNextProto[http2.NextProtoTLS] = s.serveTLSHTTP2
+
+	return nil
+}
+
+// handler returns the http.Handler serving the engine.
+func (s *Server) handler(e http.Handler) http.Handler {
+	h := abortHandler(e)
+	if s.h2Server != nil {
+		h = http2StreamHandler(h)
+	}
+	if !s.config.Proxy {
+		return s.h2cHandler(h)
+	}
+
+	return s.proxyHandler(s.h2cHandler(h), h)
+}
+
+// h2cHandler returns h, also serving HTTP/2 over cleartext if enabled.
+func (s *Server) h2cHandler(h http.Handler) http.Handler {
+	if s.h2Server == nil || s.tlsConfig != nil {
+		return h
+	}
+
+	upgrade := h2c.NewHandler(h, s.h2Server)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PRI" && len(r.Header) == 0 && r.URL.Path == "*" && r.Proto == "HTTP/2.0" {
+			s.serveH2CPriorKnowledge(w, r, h)
+			return
+		}
+		if httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "h2c") {
+			upgrade.ServeHTTP(hijackTracker{ResponseWriter: w, server: s}, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newH2CTransport returns a transport that speaks HTTP/2 over cleartext with prior knowledge.
+// dial dials the addresses, net.Dialer.DialContext if nil.
+func newH2CTransport(dial func(ctx context.Context, network, address string) (net.Conn, error)) *http2.Transport {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,534 @@
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// streamIDHeader is added by faultConn to the request headers, so the handler
+// knows the HTTP/2 stream of the request. It is removed before reaching the handlers.
+const streamIDHeader = "x-go-http-test-stream-id"
+
+// frameHeaderLen is the length of an HTTP/2 frame header.
+const frameHeaderLen = 9
+
+// maxHeaderFragment is the maximum header block fragment written in a frame,
+// which is the minimum max frame size every HTTP/2 server accepts.
+const maxHeaderFragment = 16384
+
+type faultConnKey struct{}
+
+type http2StreamKey struct{}
+
+// HTTP2Stream is the HTTP/2 stream of a request, to inject faults that can't be
+// simulated with a response.
+type HTTP2Stream struct {
+	conn *faultConn
+	id   uint32
+}
+
+// HTTP2Stream returns the HTTP/2 stream of the request,
+// or nil if the request is not made over HTTP/2.
+// h2c connections upgraded from HTTP/1.1 are not supported, only prior knowledge is.
+func (r *Request) HTTP2Stream() *HTTP2Stream {
+	stream, _ := r.Context().Value(http2StreamKey{}).(*HTTP2Stream)
+
+	return stream
+}
+
+// ID returns the stream ID.
+func (s *HTTP2Stream) ID() uint32 {
+	return s.id
+}
+
+// ConnStreams returns the number of streams opened so far on the connection of the stream.
+func (s *HTTP2Stream) ConnStreams() int {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	return s.conn.streams
+}
+
+// Reset resets the stream with the error code instead of responding.
+// It aborts the handler by panicking with http.ErrAbortHandler, so it never returns.
+func (s *HTTP2Stream) Reset(code http2.ErrCode) {
+	s.conn.mu.Lock()
+	s.conn.resetCodes[s.id] = code
+	s.conn.mu.Unlock()
+
+	panic(http.ErrAbortHandler)
+}
+
+// GoAway sends a GOAWAY frame with the error code, so the client does not open
+// new streams on the connection. The streams already opened, including this
+// one, are still served. It is sent only once per connection.
+func (s *HTTP2Stream) GoAway(code http2.ErrCode) error {
+	return s.conn.goAway(code)
+}
+
+// StallFlowControl stops the flow control of the stream: the window updates
+// of the client and of the server are dropped. Once the windows are exhausted,
+// the response body and the request body are stalled.
+func (s *HTTP2Stream) StallFlowControl() {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	s.conn.stalled[s.id] = true
+}
+
+// http2StreamHandler moves the HTTP/2 stream of the request from the header
+// added by faultConn to the request context.
+func http2StreamHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(streamIDHeader); v != "" {
+			r.Header.Del(streamIDHeader)
+			conn, ok := r.Context().Value(faultConnKey{}).(*faultConn)
+			if id, err := strconv.ParseUint(v, 10, 32); err == nil && ok {
+				stream := &HTTP2Stream{conn: conn, id: uint32(id)}
+				r = r.WithContext(context.WithValue(r.Context(), http2StreamKey{}, stream))
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveTLSHTTP2 serves an HTTP/2 connection negotiated with ALPN.
+// It replaces the one set by http2.ConfigureServer in http.Server.TLSNextProto.
+func (s *Server) serveTLSHTTP2(hs *http.Server, c *tls.Conn, h http.Handler) {
+	// net/http passes its base context with this method, like for http2.ConfigureServer.
+	ctx := context.Background()
+	if bc, ok := h.(interface{ BaseContext() context.Context }); ok {
+		ctx = bc.BaseContext()
+	}
+
+	fc := newFaultConn(c, bufio.NewReader(c), false)
+	s.serveHTTP2(&tlsFaultConn{faultConn: fc, tlsConn: c}, fc, ctx, hs, h)
+}
+
+// serveH2CPriorKnowledge serves an HTTP/2 connection over cleartext, when the
+// client starts with the HTTP/2 preface, read as a "PRI *" request by net/http.
+func (s *Server) serveH2CPriorKnowledge(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		s.config.Logger.Printf("h2c: hijack: %v", err)
+		return
+	}
+	// Closed with the server, the hijacked connections are not closed by http.Server.Close.
+	tracked, ok := s.trackHijacked(conn)
+	if !ok {
+		_ = conn.Close()
+		return
+	}
+	defer tracked.Close()
+
+	// The rest of the preface after "PRI * HTTP/2.0\r\n\r\n".
+	const expected = "SM\r\n\r\n"
+	buf := make([]byte, len(expected))
+	if _, err := io.ReadFull(rw, buf); err != nil || string(buf) != expected {
+		return
+	}
+
+	fc := newFaultConn(conn, rw.Reader, true)
+	s.serveHTTP2(fc, fc, r.Context(), s.httpServer, h)
+}
+
+// serveHTTP2 serves the connection with the HTTP/2 server, with fc in the request contexts.
+func (s *Server) serveHTTP2(conn net.Conn, fc *faultConn, ctx context.Context, hs *http.Server, h http.Handler) {
+	s.h2Server.ServeConn(conn, &http2.ServeConnOpts{
+		Context:          context.WithValue(ctx, faultConnKey{}, fc),
+		BaseConfig:       hs,
+		Handler:          h,
+		SawClientPreface: fc.sawPreface,
+	})
+}
+
+// faultConn is a connection between the HTTP/2 server and the client, that
+// reads and writes whole frames to inject the faults of HTTP2Stream.
+//
+// The request headers are decoded and re-encoded to add streamIDHeader, so both
+// HPACK states of the client and the server stay consistent. The other frames
+// are passed as is, except the ones altered by the faults.
+type faultConn struct {
+	net.Conn
+	r          *bufio.Reader
+	sawPreface bool
+
+	// Read side, only used by the server reader.
+	readBuf     []byte
+	decoder     *hpack.Decoder
+	encoder     *hpack.Encoder
+	encoderBuf  bytes.Buffer
+	framerBuf   bytes.Buffer
+	framer      *http2.Framer
+	headerBlock *headerBlock
+
+	// Write side, frames are written whole so frames can be injected in between.
+	wmu      sync.Mutex
+	pending  []byte
+	injected [][]byte
+
+	mu           sync.Mutex
+	streams      int
+	lastStreamID uint32
+	resetCodes   map[uint32]http2.ErrCode
+	stalled      map[uint32]bool
+	goAwaySent   bool
+}
+
+// headerBlock is a header block being read, split over HEADERS & CONTINUATION frames.
+type headerBlock struct {
+	streamID  uint32
+	endStream bool
+	priority  http2.PriorityParam
+	fragment  []byte
+	// raw is the frames as read, passed as is if the block cannot be decoded.
+	raw []byte
+}
+
+// tlsFaultConn is a faultConn over TLS, the HTTP/2 server gets the TLS state with ConnectionState.
+type tlsFaultConn struct {
+	*faultConn
+	tlsConn *tls.Conn
+}
+
+// ConnectionState returns the TLS state of the connection.
+func (c *tlsFaultConn) ConnectionState() tls.ConnectionState {
+	return c.tlsConn.ConnectionState()
+}
+
+// newFaultConn creates a faultConn reading from r, which buffers conn.
+// sawPreface is whether the client preface is already read from r.
+func newFaultConn(conn net.Conn, r *bufio.Reader, sawPreface bool) *faultConn {
+	c := &faultConn{
+		Conn:       conn,
+		r:          r,
+		sawPreface: sawPreface,
+		decoder:    hpack.NewDecoder(4096, nil),
+		resetCodes: map[uint32]http2.ErrCode{},
+		stalled:    map[uint32]bool{},
+	}
+	c.encoder = hpack.NewEncoder(&c.encoderBuf)
+	c.framer = http2.NewFramer(&c.framerBuf, nil)
+
+	return c
+}
+
+// Read reads the frames sent by the client, altered by the faults.
+func (c *faultConn) Read(p []byte) (int, error) {
+	for len(c.readBuf) == 0 {
+		if err := c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+
+	return n, nil
+}
+
+// readFrame reads the next frame from the client into readBuf.
+// readBuf stays empty if the frame is dropped, or is part of a header block.
+func (c *faultConn) readFrame() error {
+	if !c.sawPreface {
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(c.r, preface); err != nil {
+			return err
+		}
+		c.sawPreface = true
+		c.readBuf = preface
+		return nil
+	}
+
+	frame, err := readRawFrame(c.r)
+	if err != nil {
+		return err
+	}
+
+	header, payload := frame[:frameHeaderLen], frame[frameHeaderLen:]
+	flags := http2.Flags(header[4])
+	streamID := frameStreamID(header)
+
+	switch http2.FrameType(header[3]) {
+	case http2.FrameHeaders:
+		c.readHeaders(frame, flags, streamID, payload)
+	case http2.FrameContinuation:
+		c.readContinuation(frame, flags, streamID, payload)
+	case http2.FrameWindowUpdate:
+		if !c.isStalled(streamID) {
+			c.readBuf = frame
+		}
+	default:
+		c.readBuf = frame
+	}
+
+	return nil
+}
+
+func (c *faultConn) readHeaders(frame []byte, flags http2.Flags, streamID uint32, payload []byte) {
+	block := &headerBlock{
+		streamID:  streamID,
+		endStream: flags.Has(http2.FlagHeadersEndStream),
+		raw:       frame,
+	}
+
+	if flags.Has(http2.FlagHeadersPadded) {
+		if len(payload) < 1 || int(payload[0]) > len(payload)-1 {
+			c.readBuf = frame
+			return
+		}
+		payload = payload[1 : len(payload)-int(payload[0])]
+	}
+	if flags.Has(http2.FlagHeadersPriority) {
+		if len(payload) < 5 {
+			c.readBuf = frame
+			return
+		}
+		dep := binary.BigEndian.Uint32(payload[:4])
+		block.priority = http2.PriorityParam{
+			StreamDep: dep & (1<<31 - 1),
+			Exclusive: dep&(1<<31) != 0,
+			Weight:    payload[4],
+		}
+		payload = payload[5:]
+	}
+	block.fragment = bytes.Clone(payload)
+
+	c.headerBlock = block
+	if flags.Has(http2.FlagHeadersEndHeaders) {
+		c.readHeaderBlock()
+	}
+}
+
+func (c *faultConn) readContinuation(frame []byte, flags http2.Flags, streamID uint32, payload []byte) {
+	block := c.headerBlock
+	if block == nil || block.streamID != streamID {
+		// Invalid, let the server handle it.
+		c.readBuf = frame
+		return
+	}
+
+	block.fragment = append(block.fragment, payload...)
+	block.raw = append(block.raw, frame...)
+	if flags.Has(http2.FlagContinuationEndHeaders) {
+		c.readHeaderBlock()
+	}
+}
+
+// readHeaderBlock re-encodes the complete header block into readBuf,
+// with streamIDHeader if it opens a stream.
+func (c *faultConn) readHeaderBlock() {
+	block := c.headerBlock
+	c.headerBlock = nil
+
+	fields, err := c.decoder.DecodeFull(block.fragment)
+	if err != nil {
+		// The server fails to decode it too, and closes the connection.
+		c.readBuf = block.raw
+		return
+	}
+
+	c.encoderBuf.Reset()
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, streamIDHeader) {
+			continue
+		}
+		_ = c.encoder.WriteField(f)
+	}
+	// A header block on a stream already opened is trailers.
+	if c.openStream(block.streamID) {
+		_ = c.encoder.WriteField(hpack.HeaderField{
+			Name:  streamIDHeader,
+			Value: strconv.FormatUint(uint64(block.streamID), 10),
+		})
+	}
+
+	fragment := c.encoderBuf.Bytes()
+	first := fragment[:min(len(fragment), maxHeaderFragment)]
+	fragment = fragment[len(first):]
+
+	c.framerBuf.Reset()
+	err = c.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      block.streamID,
+		BlockFragment: first,
+		EndStream:     block.endStream,
+		EndHeaders:    len(fragment) == 0,
+		Priority:      block.priority,
+	})
+	for err == nil && len(fragment) > 0 {
+		chunk := fragment[:min(len(fragment), maxHeaderFragment)]
+		fragment = fragment[len(chunk):]
+		err = c.framer.WriteContinuation(block.streamID, len(fragment) == 0, chunk)
+	}
+	if err != nil {
+		c.readBuf = block.raw
+		return
+	}
+
+	c.readBuf = bytes.Clone(c.framerBuf.Bytes())
+}
+
+// openStream records the stream, and returns whether it is a new stream.
+func (c *faultConn) openStream(streamID uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Client streams are opened in increasing order.
+	if streamID <= c.lastStreamID {
+		return false
+	}
+	c.lastStreamID = streamID
+	c.streams++
+
+	return true
+}
+
+func (c *faultConn) isStalled(streamID uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stalled[streamID]
+}
+
+// Write writes the frames sent by the server, altered by the faults.
+// Incomplete frames are kept until the rest is written.
+func (c *faultConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.pending = append(c.pending, p...)
+
+	var out []byte
+	for len(c.pending) >= frameHeaderLen {
+		n := frameHeaderLen + frameLength(c.pending)
+		if len(c.pending) < n {
+			break
+		}
+		out = append(out, c.writeFrame(c.pending[:n])...)
+		c.pending = c.pending[n:]
+	}
+	if len(c.pending) == 0 {
+		c.pending = nil
+		for _, frame := range c.injected {
+			out = append(out, frame...)
+		}
+		c.injected = nil
+	}
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// writeFrame returns the frame to write instead of the frame written by the server.
+func (c *faultConn) writeFrame(frame []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	streamID := frameStreamID(frame)
+	switch http2.FrameType(frame[3]) {
+	case http2.FrameRSTStream:
+		if code, ok := c.resetCodes[streamID]; ok && len(frame) == frameHeaderLen+4 {
+			frame = bytes.Clone(frame)
+			binary.BigEndian.PutUint32(frame[frameHeaderLen:], uint32(code))
+		}
+	case http2.FrameWindowUpdate:
+		if c.stalled[streamID] {
+			return nil
+		}
+	}
+
+	return frame
+}
+
+// inject writes a frame between the frames written by the server.
+func (c *faultConn) inject(frame []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if len(c.pending) > 0 {
+		c.injected = append(c.injected, frame)
+		return nil
+	}
+
+	_, err := c.Conn.Write(frame)
+
+	return err
+}
+
+// goAway injects a GOAWAY frame, once per connection.
+func (c *faultConn) goAway(code http2.ErrCode) error {
+	c.mu.Lock()
+	if c.goAwaySent {
+		c.mu.Unlock()
+		return nil
+	}
+	c.goAwaySent = true
+	lastStreamID := c.lastStreamID
+	c.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := http2.NewFramer(&buf, nil).WriteGoAway(lastStreamID, code, nil); err != nil {
+		return fmt.Errorf("WriteGoAway: %w", err)
+	}
+
+	return c.inject(buf.Bytes())
+}
+
+// readRawFrame reads a whole frame, header included.
+func readRawFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, frameHeaderLen+frameLength(header))
+	copy(frame, header)
+	if _, err := io.ReadFull(r, frame[frameHeaderLen:]); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// frameLength returns the payload length from the frame header.
+func frameLength(header []byte) int {
+	return int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+}
+
+// frameStreamID returns the stream ID from the frame header.
+func frameStreamID(header []byte) uint32 {
+	return binary.BigEndian.Uint32(header[5:9]) & (1<<31 - 1)
+}
+
+// parseHTTP2ErrCode parses an HTTP/2 error code from its name, e.g. "REFUSED_STREAM", or its number.
+func parseHTTP2ErrCode(s string) (http2.ErrCode, error) {
+	if n, err := strconv.ParseUint(s, 0, 32); err == nil {
+		return http2.ErrCode(n), nil
+	}
+	for code := http2.ErrCodeNo; code <= http2.ErrCodeHTTP11Required; code++ {
+		if code.String() == strings.ToUpper(s) {
+			return code, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown HTTP/2 error code %q", s)
+}
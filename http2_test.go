@@ -0,0 +1,230 @@
+package httptest_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+	"golang.org/x/net/http2"
+)
+
+func (s *serverTestSuite) TestHTTP2() {
+	testCases := []struct {
+		name          string
+		config        httptest.ServerConfig
+		expectedProto string
+	}{
+		{
+			name:          "TLS",
+			config:        httptest.ServerConfig{TLS: true, HTTP2: true},
+			expectedProto: "HTTP/2.0",
+		},
+		{
+			name:          "h2c",
+			config:        httptest.ServerConfig{HTTP2: true},
+			expectedProto: "HTTP/2.0",
+		},
+		{
+			name:          "TLS without HTTP2",
+			config:        httptest.ServerConfig{TLS: true},
+			expectedProto: "HTTP/1.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			server, err := httptest.NewServer(address, tc.config)
+			s.NoError(err)
+			defer server.Close()
+
+			server.RegisterHandler(http.MethodPost, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+				s.Equal(tc.expectedProto, r.Proto)
+				w.SetStatusCode(http.StatusOK)
+				w.SetBodyBytes([]byte("ok"))
+			})
+
+			res, err := server.Client().Post(server.URL()+"/some-path", "text/plain", nil)
+			s.NoError(err)
+			resBody, err := io.ReadAll(res.Body)
+			s.NoError(err)
+			s.NoError(res.Body.Close())
+			s.Equal(tc.expectedProto, res.Proto)
+			s.Equal("ok", string(resBody))
+
+			calls := server.GetCalls(http.MethodPost, "/some-path")
+			s.Equal(1, len(calls))
+			s.Equal(tc.expectedProto, calls[0].Proto)
+		})
+	}
+}
+
+func (s *serverTestSuite) TestHTTP2_StillServesHTTP1() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/some-path", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("HTTP/1.1", server.GetCalls(http.MethodGet, "/some-path")[0].Proto)
+}
+
+func (s *serverTestSuite) TestHTTP2_H2CClose() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	// Prior knowledge.
+	client := server.Client()
+	res, err := client.Get(server.URL() + "/some-path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal("HTTP/2.0", res.Proto)
+
+	// Upgrade.
+	conn, err := net.Dial("tcp", address)
+	s.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /some-path HTTP/1.1\r\nHost: " + address + "\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAoAAAAAIAAAAA\r\n\r\n"))
+	s.NoError(err)
+	br := bufio.NewReader(conn)
+	res, err = http.ReadResponse(br, nil)
+	s.NoError(err)
+	s.Equal(http.StatusSwitchingProtocols, res.StatusCode)
+
+	// Close closes the h2c connections.
+	s.NoError(server.Close())
+	_, err = client.Get(server.URL() + "/some-path")
+	s.Error(err)
+	s.NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = io.ReadAll(br)
+	s.NoError(err)
+}
+
+func (s *serverTestSuite) TestHTTP2Stream_Reset() {
+	testCases := []struct {
+		name   string
+		config httptest.ServerConfig
+	}{
+		{name: "TLS", config: httptest.ServerConfig{TLS: true, HTTP2: true}},
+		{name: "h2c", config: httptest.ServerConfig{HTTP2: true}},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			server, err := httptest.NewServer(address, tc.config)
+			s.NoError(err)
+			defer server.Close()
+
+			server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+				stream := r.HTTP2Stream()
+				s.NotNil(stream)
+				stream.Reset(http2.ErrCodeEnhanceYourCalm)
+			})
+
+			_, err = server.Client().Get(server.URL() + "/some-path")
+			s.Error(err)
+			s.Contains(err.Error(), "ENHANCE_YOUR_CALM")
+			s.Equal(1, server.GetNCalls(http.MethodGet, "/some-path"))
+		})
+	}
+}
+
+func (s *serverTestSuite) TestHTTP2Stream_NilOverHTTP1() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.Nil(r.HTTP2Stream())
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/some-path", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestHTTP2Fault_GoAwayAfterStreams() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/some-path"},
+		Response: httptest.MappingResponse{Body: "ok", HTTP2Fault: &httptest.HTTP2Fault{GoAwayAfterStreams: 2}},
+	})
+	s.NoError(err)
+
+	client := server.Client()
+	var reused []bool
+	for range 3 {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+		}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, server.URL()+"/some-path", nil)
+		s.NoError(err)
+		res, err := client.Do(req)
+		s.NoError(err)
+		body, err := io.ReadAll(res.Body)
+		s.NoError(err)
+		s.NoError(res.Body.Close())
+		s.Equal("ok", string(body))
+	}
+
+	// The second stream triggers the GOAWAY, so the third request needs a new connection.
+	s.Equal([]bool{false, true, false}, reused)
+}
+
+func (s *serverTestSuite) TestHTTP2Fault_StallFlowControl() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	// Larger than the initial windows of the client.
+	body := strings.Repeat("a", 5<<20)
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/some-path"},
+		Response: httptest.MappingResponse{Body: body, HTTP2Fault: &httptest.HTTP2Fault{StallFlowControl: true}},
+	})
+	s.NoError(err)
+
+	reqCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL()+"/some-path", nil)
+	s.NoError(err)
+
+	res, err := server.Client().Do(req)
+	s.NoError(err)
+	defer res.Body.Close()
+	n, err := io.Copy(io.Discard, res.Body)
+	s.Error(err)
+	s.Less(n, int64(len(body)))
+}
+
+func (s *serverTestSuite) TestHTTP2Fault_InvalidCode() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/some-path"},
+		Response: httptest.MappingResponse{HTTP2Fault: &httptest.HTTP2Fault{ResetStream: "NOT_A_CODE"}},
+	})
+	s.Error(err)
+}
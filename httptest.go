@@ -2,26 +2,61 @@ package httptest
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
 )
 
 // Server is a mock http server for testing.
 type Server struct {
 	httpServer *http.Server
-	engine     *gin.Engine
-	// nCalls store map[method][path]count
-	nCalls map[string]map[string]int
-	// routes store map[method][path]handler
-	routes map[string]map[string]ServerHandlerFunc
-	calls  map[string]map[string][]RequestMade
+	listener   net.Listener
+	tlsConfig  *tls.Config
+	h2Server   *http2.Server
+	// engine serves the requests, with the admin routes. The other requests are
+	// dispatched to the router of their namespace, whose routes can be
+	// modified while serving.
+	engine *gin.Engine
+	// namespace is the namespace of the requests out of any scope.
+	*namespace
+	// scopes store map[scope ID]namespace
+	scopes map[string]*namespace
+	// hosts store map[virtual host name]namespace
+	hosts map[string]*namespace
+	// scopeSeq numbers the scopes, so their IDs are unique.
+	scopeSeq int
+	config   ServerConfig
+	// mappings store the registered mappings, in registration order.
+	mappings []Mapping
+	// scenarios store map[scenario]state
+	scenarios map[string]string
+	watchers  []*mappingsWatcher
+	// webSocketFrames store map[path]frames
+	webSocketFrames map[string][]WebSocketFrame
+	// webSocketConns store map[path]number of connections
+	webSocketConns map[string]int
+	// graphQLStubs store map[path]stubs, in registration order.
+	graphQLStubs map[string][]graphQLStub
+	// graphQLCalls store map[operationName]calls
+	graphQLCalls map[string][]GraphQLCall
+	// jsonrpcStubs store map[path]stubs, in registration order.
+	jsonrpcStubs map[string][]jsonrpcStub
+	// jsonrpcCalls store map[method]calls
+	jsonrpcCalls map[string][]JSONRPCCall
+	// hijacked store the hijacked connections, e.g. of the CONNECT tunnels, nil once closed.
+	hijacked map[net.Conn]struct{}
 
 	mu sync.Mutex
 }
@@ -32,48 +67,121 @@ type Request struct {
 }
 
 type RequestMade struct {
-	Body    []byte
-	Headers http.Header
-	Query   url.Values
-	Params  map[string]string
+	Body    []byte            `json:"body"`
+	Headers http.Header       `json:"headers"`
+	Query   url.Values        `json:"query"`
+	Params  map[string]string `json:"params"`
+	// RawBody is the body as received if the request has a Content-Encoding,
+	// Body is then the decoded body.
+	RawBody []byte `json:"rawBody,omitempty"`
+	// BodyError is the error decoding the body, in which case Body is the raw body.
+	BodyError string `json:"bodyError,omitempty"`
+	// Proto is the protocol of the request, e.g. "HTTP/1.1" or "HTTP/2.0".
+	Proto string `json:"proto"`
+	// PeerCertificate is the client certificate identity in mutual TLS, nil otherwise.
+	PeerCertificate *PeerCertificate `json:"peerCertificate,omitempty"`
+	// Form is the parsed form of an application/x-www-form-urlencoded or
+	// multipart/form-data body, without the uploaded files.
+	Form url.Values `json:"form,omitempty"`
+	// Parts are the parts of a multipart/form-data body, uploaded files included.
+	Parts []FormPart `json:"parts,omitempty"`
 }
 
 // ServerHandlerFunc is the interface of the handler function.
 type ServerHandlerFunc func(w ResponseWriter, r *Request)
 
 type ServerConfig struct {
-	// Nothing here yet.
+	// TLS makes the server serve HTTPS instead of HTTP, with certificates
+	// issued by CA for the host requested by the client.
+	// Use Server.Client or Server.CertPool to trust them.
+	TLS bool
+	// CA issues the server certificates. It is generated if nil.
+	CA *CertificateAuthority
+	// TLSConfig, if set, makes the server serve HTTPS using it.
+	// If it has no certificate, the certificates are issued by CA like with TLS.
+	TLSConfig *tls.Config
+	// ClientAuth enables mutual TLS, e.g. tls.RequireAndVerifyClientCert.
+	// The client certificates are verified against ClientCAs, or CA if not set.
+	// Use Server.IssueClientCertificate to mint client certificates.
+	ClientAuth tls.ClientAuthType
+	// ClientCAs verifies the client certificates instead of CA.
+	ClientCAs *x509.CertPool
+	// HTTP2 enables HTTP/2, negotiated with ALPN over TLS, and cleartext h2c otherwise.
+	// HTTP/1.1 is still served.
+	HTTP2 bool
+	// Proxy makes the server an HTTP forward proxy, e.g. the HTTP_PROXY target:
+	// the CONNECT tunnels are served like the requests received directly,
+	// intercepting TLS with certificates issued by CA for the requested hosts.
+	// The absolute-form requests of plain HTTP proxying are served whatever Proxy.
+	// The requests are routed to the virtual hosts of their host, see Server.Host.
+	// Use Server.ProxyTransport or Server.ProxyURL to send requests through it.
+	Proxy bool
+	// Network is the network to listen on, "tcp" by default, or "unix" to
+	// listen on the Unix domain socket whose path is the address.
+	// Server.Client dials the socket, whatever the requested URL.
+	Network string
+	// Logger is used for the server logs. Defaults to log.Default().
+	Logger *log.Logger
+	// Verbose logs every request received by the server.
+	Verbose bool
+	// EnableAdmin serves the admin API under /__admin, to manage the server remotely.
+	// See the adminclient package for a Go client.
+	EnableAdmin bool
 }
 
+// setGinMode sets gin to release mode to avoid unnecessary logs.
+// The mode is global, it is set once so it does not race with the servers in use.
+var setGinMode = sync.OnceFunc(func() {
+	gin.SetMode(gin.ReleaseMode)
+})
+
 // NewServer creates and starts new http test server.
-// address is the address to listen on, e.g. "localhost:3001".
+// address is the address to listen on, e.g. "localhost:3001", or the path of
+// a Unix domain socket prefixed with UnixScheme, e.g. "unix:///tmp/server.sock".
 func NewServer(address string, config ServerConfig) (*Server, error) {
-	// Set gin to release mode to avoid unnecessary logs.
-	gin.SetMode(gin.ReleaseMode)
+	setGinMode()
+
+	network, address := listenAddress(address, config.Network)
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
 
 	// Start listener first to make sure the address is available.
-	l, err := net.Listen("tcp", address)
+	// The socket file of a Unix listener is removed when it is closed.
+	l, err := net.Listen(network, address)
 	if err != nil {
 		return nil, fmt.Errorf("net.Listen: %w", err)
 	}
 
-	r := gin.Default()
-
-	httpServer := &http.Server{
-		Addr:    address,
-		Handler: r.Handler(),
+	var tlsConfig *tls.Config
+	if config.TLS || config.TLSConfig != nil || config.ClientAuth != tls.NoClientCert {
+		tlsConfig, err = newTLSConfig(address, &config)
+		if err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+		l = tls.NewListener(l, tlsConfig)
+	}
+	if config.Proxy && config.CA == nil {
+		if config.CA, err = NewCertificateAuthority(); err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("NewCertificateAuthority: %w", err)
+		}
 	}
 
-	server := &Server{
-		engine:     r,
-		httpServer: httpServer,
-		nCalls:     map[string]map[string]int{},
-		routes:     map[string]map[string]ServerHandlerFunc{},
-		calls:      map[string]map[string][]RequestMade{},
+	server := newServer(address, config, l, tlsConfig)
+	if config.HTTP2 {
+		if err := server.configureHTTP2(); err != nil {
+			_ = l.Close()
+			return nil, err
+		}
 	}
+	server.httpServer.Handler = server.handler(server.engine.Handler())
 
 	go func() {
-		if err = httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err = server.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(err)
 		}
 	}()
@@ -81,19 +189,108 @@ func NewServer(address string, config ServerConfig) (*Server, error) {
 	return server, nil
 }
 
+// newServer creates a server, without serving it.
+// listener and tlsConfig are nil for an in-memory server.
+func newServer(address string, config ServerConfig, l net.Listener, tlsConfig *tls.Config) *Server {
+	if config.Logger == nil {
+		config.Logger = log.Default()
+	}
+
+	server := &Server{
+		namespace: newNamespace(),
+		scopes:    map[string]*namespace{},
+		hosts:     map[string]*namespace{},
+		scenarios: map[string]string{},
+		config:    config,
+		listener:  l,
+		tlsConfig: tlsConfig,
+
+		webSocketFrames: map[string][]WebSocketFrame{},
+		webSocketConns:  map[string]int{},
+		graphQLStubs:    map[string][]graphQLStub{},
+		graphQLCalls:    map[string][]GraphQLCall{},
+		jsonrpcStubs:    map[string][]jsonrpcStub{},
+		jsonrpcCalls:    map[string][]JSONRPCCall{},
+		hijacked:        map[net.Conn]struct{}{},
+	}
+	server.engine = server.newEngine()
+	server.httpServer = &http.Server{
+		Addr: address,
+	}
+
+	return server
+}
+
+// newEngine creates a gin engine with the middlewares required by the config.
+func (s *Server) newEngine() *gin.Engine {
+	// Without the gin logger, the requests are only logged if Verbose.
+	e := gin.New()
+	e.Use(gin.Recovery())
+	if s.config.Verbose {
+		e.Use(s.logRequest)
+	}
+	if s.config.EnableAdmin {
+		s.registerAdminRoutes(e)
+	}
+	e.NoRoute(s.dispatch)
+
+	return e
+}
+
+// logRequest is a middleware that logs the request and the response status.
+func (s *Server) logRequest(c *gin.Context) {
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = c.GetString(routeKey)
+	}
+	if route == "" {
+		route = "no route"
+	}
+	size := c.Writer.Size()
+	if size < 0 {
+		size = 0
+	}
+	s.config.Logger.Printf(
+		"%s %s (%s) -> %d, %d bytes",
+		c.Request.Method,
+		c.Request.URL.RequestURI(),
+		route,
+		c.Writer.Status(),
+		size,
+	)
+}
+
 // Close closes the server.
 func (s *Server) Close() error {
-	return s.httpServer.Close()
+	s.mu.Lock()
+	for _, w := range s.watchers {
+		_ = w.watcher.Close()
+	}
+	s.watchers = nil
+	s.mu.Unlock()
+
+	err := s.httpServer.Close()
+	s.closeHijacked()
+	if s.listener == nil {
+		return err
+	}
+	// Close the listener explicitly, in case Serve has not started yet,
+	// so the address can be reused right away.
+	if lErr := s.listener.Close(); lErr != nil && !errors.Is(lErr, net.ErrClosed) && err == nil {
+		err = lErr
+	}
+
+	return err
 }
 
 // GetNCalls returns the number of nCalls for a path.
 func (s *Server) GetNCalls(method, path string) int {
-	calls, ok := s.nCalls[method][path]
-	if !ok {
-		return 0
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return calls
+	return s.nCalls[method][path]
 }
 
 // ResetNCalls resets the number of nCalls for all paths.
@@ -101,90 +298,174 @@ func (s *Server) ResetNCalls() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for path := range s.nCalls {
-		for method := range s.nCalls[path] {
-			s.nCalls[path][method] = 0
-		}
-	}
+	s.resetNCalls()
 }
 
-// GetCalls returns the calls for a path.
+// GetCalls returns a copy of the calls for a path, in order.
 func (s *Server) GetCalls(method, path string) []RequestMade {
-	return s.calls[method][path]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getCalls(method, path)
 }
 
-// ResetCalls resets the calls & nCalls for all paths.
+// ResetCalls resets the calls, nCalls, WebSocket frames, GraphQL & JSON-RPC calls for all paths, virtual hosts included.
 // It does not reset the handlers.
 func (s *Server) ResetCalls() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.ResetNCalls()
-	for path := range s.calls {
-		for method := range s.calls[path] {
-			s.calls[path][method] = []RequestMade{}
-		}
+	s.resetCalls()
+	for _, ns := range s.hosts {
+		ns.resetCalls()
 	}
+	s.webSocketFrames = map[string][]WebSocketFrame{}
+	s.webSocketConns = map[string]int{}
+	s.graphQLCalls = map[string][]GraphQLCall{}
+	s.jsonrpcCalls = map[string][]JSONRPCCall{}
 }
 
 // RegisterHandler registers handler of a path.
 // Registering same path twice will overwrite the previous handler.
+// It is safe to call while the server serves requests: the requests in flight
+// finish with the previous handler, the next ones are served with the new one.
+// It panics if the path is invalid or conflicts with another route, see Register.
 func (s *Server) RegisterHandler(method string, path string, handler ServerHandlerFunc) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if err := s.router.add(method, route{path: path, handler: handler}); err != nil {
+		panic(err)
+	}
+}
 
-	if s.nCalls[method] == nil {
-		s.nCalls[method] = map[string]int{}
-	}
-	if s.routes[method] == nil {
-		s.routes[method] = map[string]ServerHandlerFunc{}
-	}
-	if s.calls[method] == nil {
-		s.calls[method] = map[string][]RequestMade{}
-	}
-
-	if s.routes[method] != nil && s.routes[method][path] != nil {
-		// Regenerate the handler and re-register all handlers.
-		s.engine = gin.Default()
-		for m, p := range s.routes {
-			for k, v := range p {
-				// Skip same one, we'll register it below.
-				if m == method && k == path {
-					continue
-				}
-				s.engine.Handle(m, k, func(c *gin.Context) {
-					s.incrNCalls(m, k)
-					s.storeCall(m, k, c)
-					v(ResponseWriter{w: c.Writer}, &Request{Request: c.Request, Params: Params{ginContext: c}})
-				})
-			}
-		}
-		s.httpServer.Handler = s.engine.Handler()
+// RouteOptions are the options of a route registered with Server.Register.
+type RouteOptions struct {
+	// Priority orders the routes matching a request: the route with the highest
+	// priority responds. Among the routes of the same priority, the most specific
+	// one responds: static segments win over params, and params over catch-alls.
+	// Defaults to 0.
+	Priority int
+}
+
+// Register registers handler of a path like RegisterHandler, and returns the
+// ID of the route, to remove it with Unregister.
+// It returns an error if the path is invalid, or conflicts with another route,
+// e.g. "/users/:name" with "/users/:id".
+func (s *Server) Register(method string, path string, handler ServerHandlerFunc, opts RouteOptions) (string, error) {
+	return s.register(method, path, handler, opts)
+}
+
+// Unregister removes the route registered by Register with the ID.
+// It reports whether the route was registered, and not replaced since.
+func (s *Server) Unregister(id string) bool {
+	return s.router.removeID(id)
+}
+
+// UnregisterHandler removes the handler of a path, the path is then not found.
+// The calls made to the path are kept.
+// It reports whether a handler was registered on the method & path.
+func (s *Server) UnregisterHandler(method string, path string) bool {
+	return s.router.remove(method, path)
+}
+
+// routeKey is the gin context key of the registered path matching the request.
+const routeKey = "httptest.route"
+
+// dispatch serves the requests not matching the engine routes with the router
+// of their namespace.
+func (s *Server) dispatch(c *gin.Context) {
+	ns, prefix, ok := s.requestNamespace(c.Request)
+	if !ok {
+		return
+	}
+
+	method, path := c.Request.Method, strings.TrimPrefix(c.Request.URL.Path, prefix)
+	matched, params := ns.router.lookup(method, path)
+	if matched == nil {
+		redirectTrailingSlash(c, ns, prefix, path)
+		return
+	}
+	if prefix != "" {
+		// The handlers see the path without the scope prefix.
+		c.Request.URL.Path, c.Request.URL.RawPath = path, ""
+	}
+
+	c.Params = params
+	c.Set(routeKey, matched.path)
+	// gin sets the not found status before dispatching.
+	c.Status(http.StatusOK)
+
+	s.incrNCalls(ns, method, matched.path)
+	s.storeCall(ns, method, matched.path, c)
+	defer recoverAbort(c)
+	matched.handler(ResponseWriter{w: c.Writer, r: c.Request}, &Request{Request: c.Request, Params: Params{ginContext: c}})
+	// Write the status now, or gin writes its not found body.
+	c.Writer.WriteHeaderNow()
+}
+
+// redirectTrailingSlash redirects the request to the path with or without the
+// trailing slash if it has a route, like the gin engine does.
+func redirectTrailingSlash(c *gin.Context, ns *namespace, prefix, path string) {
+	if strings.HasSuffix(path, "/") {
+		path = strings.TrimSuffix(path, "/")
+	} else {
+		path += "/"
 	}
-	s.routes[method][path] = handler
+	if path == "" {
+		return
+	}
+	if matched, _ := ns.router.lookup(c.Request.Method, path); matched == nil {
+		return
+	}
+
+	code := http.StatusMovedPermanently
+	if c.Request.Method != http.MethodGet {
+		code = http.StatusTemporaryRedirect
+	}
+	u := *c.Request.URL
+	u.Path, u.RawPath = prefix+path, ""
+	c.Redirect(code, u.RequestURI())
+}
 
-	s.engine.Handle(method, path, func(c *gin.Context) {
-		s.incrNCalls(method, path)
-		s.storeCall(method, path, c)
-		handler(ResponseWriter{w: c.Writer}, &Request{Request: c.Request, Params: Params{ginContext: c}})
+type abortKey struct{}
+
+// abortHandler serves h, and aborts the response by panicking with
+// http.ErrAbortHandler if a handler did, like net/http does.
+// gin recovers the panics, so the handlers record it in the request context
+// instead, see recoverAbort.
+func abortHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aborted := false
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), abortKey{}, &aborted)))
+		if aborted {
+			panic(http.ErrAbortHandler)
+		}
 	})
+}
 
-	s.httpServer.Handler = s.engine.Handler()
+// recoverAbort records the http.ErrAbortHandler panic of a handler for abortHandler.
+// Other panics are left to gin.
+func recoverAbort(c *gin.Context) {
+	if err := recover(); err != nil {
+		aborted, ok := c.Request.Context().Value(abortKey{}).(*bool)
+		if err != http.ErrAbortHandler || !ok {
+			panic(err)
+		}
+		*aborted = true
+		c.Abort()
+	}
 }
 
 // incrNCalls increments the number of nCalls for a path.
-func (s *Server) incrNCalls(method, path string) {
+func (s *Server) incrNCalls(ns *namespace, method, path string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.nCalls[method][path]++
+	// The maps are reset by ResetAll, possibly while the request was dispatched.
+	ns.initMethod(method)
+	ns.nCalls[method][path]++
 }
 
 // storeCall stores the call for a path.
-func (s *Server) storeCall(method, path string, c *gin.Context) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
+func (s *Server) storeCall(ns *namespace, method, path string, c *gin.Context) {
 	// If body is not empty, read it into byte.
 	var body []byte
 	if c.Request.Body != nil {
@@ -193,12 +474,31 @@ func (s *Server) storeCall(method, path string, c *gin.Context) {
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
 	}
 
-	s.calls[method][path] = append(s.calls[method][path], RequestMade{
-		Body:    body,
-		Headers: c.Request.Header,
+	call := RequestMade{
+		Body: body,
+		// The handler may modify the request headers, record a copy.
+		Headers: c.Request.Header.Clone(),
 		Query:   c.Request.URL.Query(),
 		Params:  s.getAllParams(c),
-	})
+		Proto:   c.Request.Proto,
+
+		PeerCertificate: newPeerCertificate(c.Request.TLS),
+	}
+	if len(contentEncodings(c.Request.Header)) > 0 {
+		call.RawBody = body
+		if decoded, err := decodeBody(c.Request.Header, body); err != nil {
+			call.BodyError = err.Error()
+		} else {
+			call.Body = decoded
+		}
+	}
+	call.Form, call.Parts = parseForm(c.Request.Header.Get("Content-Type"), call.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns.initMethod(method)
+	ns.calls[method][path] = append(ns.calls[method][path], call)
 }
 
 func (s *Server) getAllParams(c *gin.Context) map[string]string {
@@ -211,14 +511,22 @@ func (s *Server) getAllParams(c *gin.Context) map[string]string {
 	return params
 }
 
-// ResetAll resets all the nCalls, handlers, and calls.
+// ResetAll resets all the nCalls, handlers, calls, WebSocket frames, GraphQL & JSON-RPC stubs, mappings, and scenarios.
+// The virtual hosts are kept, without handlers nor calls.
 func (s *Server) ResetAll() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.engine = gin.Default()
-	s.httpServer.Handler = s.engine.Handler()
-	s.nCalls = map[string]map[string]int{}
-	s.routes = map[string]map[string]ServerHandlerFunc{}
-	s.calls = map[string]map[string][]RequestMade{}
+	s.resetAll()
+	for _, ns := range s.hosts {
+		ns.resetAll()
+	}
+	s.mappings = nil
+	s.scenarios = map[string]string{}
+	s.webSocketFrames = map[string][]WebSocketFrame{}
+	s.webSocketConns = map[string]int{}
+	s.graphQLStubs = map[string][]graphQLStub{}
+	s.graphQLCalls = map[string][]GraphQLCall{}
+	s.jsonrpcStubs = map[string][]jsonrpcStub{}
+	s.jsonrpcCalls = map[string][]JSONRPCCall{}
 }
@@ -3,12 +3,14 @@ package httptest_test
 import (
 	"context"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/suite"
 
 	httptest "github.com/slzhffktm/go-http-test"
@@ -330,3 +332,40 @@ func (s *serverTestSuite) TestResetAll() {
 	s.Equal(0, server.GetNCalls(http.MethodGet, path))
 	s.Equal(0, len(server.GetCalls(http.MethodGet, path)))
 }
+
+func (s *serverTestSuite) TestVerbose() {
+	// The gin logger writes to gin.DefaultWriter, read when the server is created.
+	ginOut := &syncBuffer{}
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = ginOut
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	for _, verbose := range []bool{false, true} {
+		logs := &syncBuffer{}
+		server, err := httptest.NewServer(address, httptest.ServerConfig{Logger: log.New(logs, "", 0), Verbose: verbose})
+		s.NoError(err)
+
+		server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(http.StatusOK)
+		})
+		res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/some-path", nil, nil, nil)
+		s.NoError(err)
+		s.Equal(http.StatusOK, res.StatusCode)
+		s.NoError(server.Close())
+		http.DefaultClient.CloseIdleConnections()
+
+		// The requests are only logged if verbose, once.
+		if verbose {
+			s.Equal("GET /some-path (/some-path) -> 200, 0 bytes\n", logs.String())
+		} else {
+			s.Empty(logs.String())
+		}
+	}
+	s.Empty(ginOut.String())
+}
+
+func (s *serverTestSuite) SetupTest() {
+	// Each test starts a new server on the same address, so the connections
+	// kept alive from the previous test are no longer usable.
+	http.DefaultClient.CloseIdleConnections()
+}
@@ -0,0 +1,250 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// JSON-RPC 2.0 standard error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+// JSONRPCRequest is a JSON-RPC 2.0 request.
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID is nil for a notification.
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+// DecodeParams unmarshals the params into v.
+func (r JSONRPCRequest) DecodeParams(v any) error {
+	if err := json.Unmarshal(r.Params, v); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// JSONRPCCall is a JSON-RPC request made to the server.
+type JSONRPCCall struct {
+	// Path is the path of the JSON-RPC endpoint, e.g. "/rpc".
+	Path string `json:"path"`
+	JSONRPCRequest
+	Headers http.Header `json:"headers"`
+}
+
+// JSONRPCMatch describes which params a JSON-RPC stub handles.
+type JSONRPCMatch struct {
+	// Params matches the request params, any params if nil.
+	// A map matches the by-name params with these values, other params are
+	// ignored. Other values, e.g. a slice for by-position params, must be equal.
+	Params any
+}
+
+// JSONRPCResponse is the response of a JSON-RPC stub.
+type JSONRPCResponse struct {
+	// Result is marshalled to JSON as the result.
+	Result any
+	// Error, if set, is returned instead of the result.
+	Error *JSONRPCError
+}
+
+// JSONRPCError is a JSON-RPC error object.
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// jsonrpcStub is a registered JSON-RPC stub.
+type jsonrpcStub struct {
+	method   string
+	match    JSONRPCMatch
+	response JSONRPCResponse
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response.
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RegisterJSONRPC registers a JSON-RPC 2.0 stub of a method on a path, e.g. "/rpc".
+// Several stubs can be registered on the same path, the latest registered
+// stub of the method matching the params responds. The response has the id of
+// the request, and batch requests are answered with a batch response.
+//
+// The requests of an unknown method get a JSONRPCMethodNotFound error, and the
+// ones matching no stub of the method a JSONRPCInvalidParams error.
+//
+// The calls are recorded like the other calls, as POST requests on the path,
+// and per method, see Server.GetJSONRPCCalls.
+func (s *Server) RegisterJSONRPC(path, method string, match JSONRPCMatch, response JSONRPCResponse) {
+	s.mu.Lock()
+	s.jsonrpcStubs[path] = append(s.jsonrpcStubs[path], jsonrpcStub{method: method, match: match, response: response})
+	s.mu.Unlock()
+
+	s.RegisterHandler(http.MethodPost, path, s.jsonrpcHandler(path))
+}
+
+// jsonrpcHandler returns the handler that dispatches the JSON-RPC requests of a path to the matching stubs.
+func (s *Server) jsonrpcHandler(path string) ServerHandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		body, err := r.DecodedBody()
+		body = bytes.TrimSpace(body)
+
+		if err != nil || !json.Valid(body) {
+			_, _ = w.SetBodyJSON(jsonrpcErrorResponse(nil, JSONRPCParseError, "parse error"))
+			return
+		}
+
+		if body[0] != '[' {
+			res, ok := s.handleJSONRPC(path, r, body)
+			if !ok {
+				w.SetStatusCode(http.StatusNoContent)
+				return
+			}
+			_, _ = w.SetBodyJSON(res)
+			return
+		}
+
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+			_, _ = w.SetBodyJSON(jsonrpcErrorResponse(nil, JSONRPCInvalidRequest, "invalid request"))
+			return
+		}
+		responses := []jsonrpcResponse{}
+		for _, raw := range batch {
+			if res, ok := s.handleJSONRPC(path, r, raw); ok {
+				responses = append(responses, res)
+			}
+		}
+		// A batch of notifications has no response.
+		if len(responses) == 0 {
+			w.SetStatusCode(http.StatusNoContent)
+			return
+		}
+		_, _ = w.SetBodyJSON(responses)
+	}
+}
+
+// handleJSONRPC records a request and finds its response.
+// It returns false for a notification, which has no response.
+func (s *Server) handleJSONRPC(path string, r *Request, raw json.RawMessage) (jsonrpcResponse, bool) {
+	var req JSONRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return jsonrpcErrorResponse(req.ID, JSONRPCInvalidRequest, "invalid request"), true
+	}
+
+	s.mu.Lock()
+	s.jsonrpcCalls[req.Method] = append(s.jsonrpcCalls[req.Method], JSONRPCCall{
+		Path:           path,
+		JSONRPCRequest: req,
+		Headers:        r.Header.Clone(),
+	})
+	stub, found, ok := s.matchJSONRPC(path, req)
+	s.mu.Unlock()
+
+	if req.ID == nil {
+		return jsonrpcResponse{}, false
+	}
+	if !found {
+		return jsonrpcErrorResponse(req.ID, JSONRPCMethodNotFound, fmt.Sprintf("method %q not found", req.Method)), true
+	}
+	if !ok {
+		return jsonrpcErrorResponse(req.ID, JSONRPCInvalidParams, fmt.Sprintf("no stub of method %q matched the params", req.Method)), true
+	}
+
+	if stub.response.Error != nil {
+		return jsonrpcResponse{JSONRPC: "2.0", Error: stub.response.Error, ID: req.ID}, true
+	}
+	result, err := json.Marshal(stub.response.Result)
+	if err != nil {
+		return jsonrpcErrorResponse(req.ID, JSONRPCInternalError, fmt.Sprintf("json.Marshal: %v", err)), true
+	}
+
+	return jsonrpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}, true
+}
+
+// matchJSONRPC finds the latest registered stub of the path matching the request.
+// found is whether the path has stubs of the method.
+// The caller must hold s.mu.
+func (s *Server) matchJSONRPC(path string, req JSONRPCRequest) (stub jsonrpcStub, found bool, ok bool) {
+	stubs := s.jsonrpcStubs[path]
+	for i := len(stubs) - 1; i >= 0; i-- {
+		if stubs[i].method != req.Method {
+			continue
+		}
+		found = true
+		if stubs[i].match.matches(req.Params) {
+			return stubs[i], true, true
+		}
+	}
+
+	return jsonrpcStub{}, found, false
+}
+
+// matches returns whether the params match.
+func (m JSONRPCMatch) matches(params json.RawMessage) bool {
+	if m.Params == nil {
+		return true
+	}
+
+	var actual any
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &actual); err != nil {
+			return false
+		}
+	}
+
+	expected := normalizeJSON(m.Params)
+	expectedMap, isMap := expected.(map[string]any)
+	actualMap, isActualMap := actual.(map[string]any)
+	if !isMap || !isActualMap {
+		return reflect.DeepEqual(expected, actual)
+	}
+	for k, v := range expectedMap {
+		if a, ok := actualMap[k]; !ok || !reflect.DeepEqual(v, a) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// jsonrpcErrorResponse returns an error response, with a null id if id is nil.
+func jsonrpcErrorResponse(id json.RawMessage, code int, message string) jsonrpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return jsonrpcResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: code, Message: message}, ID: id}
+}
+
+// GetJSONRPCCalls returns the JSON-RPC calls of a method, notifications included, in order.
+func (s *Server) GetJSONRPCCalls(method string) []JSONRPCCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]JSONRPCCall(nil), s.jsonrpcCalls[method]...)
+}
+
+// GetJSONRPCNCalls returns the number of JSON-RPC calls of a method, notifications included.
+func (s *Server) GetJSONRPCNCalls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.jsonrpcCalls[method])
+}
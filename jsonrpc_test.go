@@ -0,0 +1,132 @@
+package httptest_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestRegisterJSONRPC() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterJSONRPC("/rpc", "eth_getBalance", httptest.JSONRPCMatch{}, httptest.JSONRPCResponse{
+		Result: "0x0",
+	})
+	server.RegisterJSONRPC("/rpc", "eth_getBalance", httptest.JSONRPCMatch{
+		Params: []any{"0xabc", "latest"},
+	}, httptest.JSONRPCResponse{
+		Result: "0x10",
+	})
+	server.RegisterJSONRPC("/rpc", "user.get", httptest.JSONRPCMatch{
+		Params: map[string]any{"id": 1},
+	}, httptest.JSONRPCResponse{
+		Error: &httptest.JSONRPCError{Code: -32000, Message: "user not found", Data: map[string]any{"id": 1}},
+	})
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "default stub",
+			body:           `{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xdef", "latest"], "id": 1}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "result": "0x0", "id": 1}`,
+		},
+		{
+			name:           "by-position params",
+			body:           `{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xabc", "latest"], "id": "a"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "result": "0x10", "id": "a"}`,
+		},
+		{
+			name:           "by-name params and error",
+			body:           `{"jsonrpc": "2.0", "method": "user.get", "params": {"id": 1, "fields": ["name"]}, "id": 2}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32000, "message": "user not found", "data": {"id": 1}}, "id": 2}`,
+		},
+		{
+			name:           "params not matched",
+			body:           `{"jsonrpc": "2.0", "method": "user.get", "params": {"id": 2}, "id": 3}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "no stub of method \"user.get\" matched the params"}, "id": 3}`,
+		},
+		{
+			name:           "method not found",
+			body:           `{"jsonrpc": "2.0", "method": "unknown", "id": 4}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method \"unknown\" not found"}, "id": 4}`,
+		},
+		{
+			name:           "parse error",
+			body:           `{"jsonrpc": "2.0", "method"`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "parse error"}, "id": null}`,
+		},
+		{
+			name:           "invalid request",
+			body:           `{"method": "eth_getBalance", "id": 5}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "invalid request"}, "id": 5}`,
+		},
+		{
+			name:           "notification",
+			body:           `{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xabc", "latest"]}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "batch",
+			body: `[
+				{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xabc", "latest"], "id": 6},
+				{"jsonrpc": "2.0", "method": "eth_getBalance", "params": ["0xabc", "latest"]},
+				{"jsonrpc": "2.0", "method": "unknown", "id": 7},
+				1
+			]`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `[
+				{"jsonrpc": "2.0", "result": "0x10", "id": 6},
+				{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method \"unknown\" not found"}, "id": 7},
+				{"jsonrpc": "2.0", "error": {"code": -32600, "message": "invalid request"}, "id": null}
+			]`,
+		},
+		{
+			name:           "empty batch",
+			body:           `[]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "invalid request"}, "id": null}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			res, body, err := s.httpClient.Do(ctx, http.MethodPost, "/rpc", map[string]string{
+				"Content-Type": "application/json",
+			}, []byte(tc.body), nil)
+			s.NoError(err)
+			s.Equal(tc.expectedStatus, res.StatusCode)
+			if tc.expectedBody == "" {
+				s.Empty(body)
+				return
+			}
+			s.JSONEq(tc.expectedBody, string(body))
+		})
+	}
+
+	s.Equal(5, server.GetJSONRPCNCalls("eth_getBalance"))
+	s.Equal(2, server.GetJSONRPCNCalls("user.get"))
+	s.Equal(2, server.GetJSONRPCNCalls("unknown"))
+
+	calls := server.GetJSONRPCCalls("user.get")
+	var params map[string]any
+	s.NoError(calls[0].DecodeParams(&params))
+	s.Equal(map[string]any{"id": float64(1), "fields": []any{"name"}}, params)
+	s.Equal(json.RawMessage("2"), calls[0].ID)
+
+	server.ResetCalls()
+	s.Equal(0, server.GetJSONRPCNCalls("eth_getBalance"))
+}
@@ -0,0 +1,448 @@
+package httptest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Mapping is a stub definition that can be stored in a file.
+// It allows the same fixtures to be used by Go tests and by the standalone
+// server in cmd/go-http-test.
+type Mapping struct {
+	// ID identifies the mapping. It is generated on registration if empty.
+	ID       string          `json:"id,omitempty"`
+	Request  MappingRequest  `json:"request"`
+	Response MappingResponse `json:"response"`
+
+	// Scenario makes the mapping stateful. The mapping only matches when the
+	// scenario is in RequiredState (if set), and moves the scenario to NewState
+	// (if set) once matched. Every scenario starts in ScenarioStarted.
+	Scenario      string `json:"scenario,omitempty"`
+	RequiredState string `json:"requiredState,omitempty"`
+	NewState      string `json:"newState,omitempty"`
+}
+
+// ScenarioStarted is the initial state of every scenario.
+const ScenarioStarted = "Started"
+
+// EncodingAccepted is the MappingResponse.Encoding compressing the body with
+// the encoding preferred by the client.
+const EncodingAccepted = "accepted"
+
+// MappingRequest describes which requests a Mapping handles.
+type MappingRequest struct {
+	Method string `json:"method"`
+	// Path supports path parameter using `/:pathparam`, same as RegisterHandler,
+	// and patterns, e.g. "regex:/v[0-9]+/users" or "glob:/static/*.js", see RegexPath and GlobPath.
+	Path string `json:"path"`
+}
+
+// MappingResponse describes the response returned by a Mapping.
+type MappingResponse struct {
+	// Status defaults to 200 if not set.
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is returned as is.
+	Body string `json:"body,omitempty"`
+	// JSONBody is returned with Content-Type application/json.
+	// It takes precedence over Body.
+	JSONBody json.RawMessage `json:"jsonBody,omitempty"`
+	// Encoding compresses the body: gzip, deflate, br or zstd, even if the client
+	// does not accept it, or "accepted" for the encoding preferred by the client.
+	// A Content-Encoding set in Headers is kept, e.g. to send a wrong one.
+	Encoding string `json:"encoding,omitempty"`
+	// HTTP2Fault injects an HTTP/2 fault, it is ignored for the other protocols.
+	HTTP2Fault *HTTP2Fault `json:"http2Fault,omitempty"`
+}
+
+// HTTP2Fault describes the HTTP/2 faults of a MappingResponse, see HTTP2Stream.
+// The error codes are names, e.g. "REFUSED_STREAM", or numbers.
+type HTTP2Fault struct {
+	// ResetStream resets the stream with the error code instead of responding.
+	ResetStream string `json:"resetStream,omitempty"`
+	// GoAwayAfterStreams sends a GOAWAY frame once the connection has opened
+	// that many streams, the response of the stream is still sent.
+	GoAwayAfterStreams int `json:"goAwayAfterStreams,omitempty"`
+	// GoAwayCode is the error code of the GOAWAY frame, NO_ERROR by default.
+	GoAwayCode string `json:"goAwayCode,omitempty"`
+	// StallFlowControl stalls the flow control of the stream, see HTTP2Stream.StallFlowControl.
+	StallFlowControl bool `json:"stallFlowControl,omitempty"`
+}
+
+// validate checks that the error codes are valid.
+func (f *HTTP2Fault) validate() error {
+	for _, code := range []string{f.ResetStream, f.GoAwayCode} {
+		if code == "" {
+			continue
+		}
+		if _, err := parseHTTP2ErrCode(code); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inject injects the faults on the stream.
+// It does not return if the stream is reset.
+func (f *HTTP2Fault) inject(stream *HTTP2Stream) {
+	if f.StallFlowControl {
+		stream.StallFlowControl()
+	}
+	if f.GoAwayAfterStreams > 0 && stream.ConnStreams() >= f.GoAwayAfterStreams {
+		code, _ := parseHTTP2ErrCode(f.GoAwayCode)
+		_ = stream.GoAway(code)
+	}
+	if f.ResetStream != "" {
+		code, _ := parseHTTP2ErrCode(f.ResetStream)
+		stream.Reset(code)
+	}
+}
+
+// mappingFile is the format of a mapping file with multiple mappings.
+type mappingFile struct {
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Validate checks that the mapping has the required fields.
+func (m Mapping) Validate() error {
+	if m.Request.Method == "" {
+		return fmt.Errorf("request.method is required")
+	}
+	if pattern, err := compilePathPattern(m.Request.Path); err != nil {
+		return fmt.Errorf("request.path: %w", err)
+	} else if pattern == nil && !strings.HasPrefix(m.Request.Path, "/") {
+		return fmt.Errorf("request.path must start with /")
+	}
+	if e := m.Response.Encoding; e != "" && e != EncodingAccepted && !slices.Contains(supportedEncodings, e) {
+		return fmt.Errorf("response.encoding %q is not supported", e)
+	}
+	if m.Response.HTTP2Fault != nil {
+		if err := m.Response.HTTP2Fault.validate(); err != nil {
+			return fmt.Errorf("response.http2Fault: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Handler returns the ServerHandlerFunc that writes the mapping response.
+// It does not take the scenario into account, use Server.RegisterMapping for that.
+func (m Mapping) Handler() ServerHandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		m.writeResponse(w, r)
+	}
+}
+
+// writeResponse writes the mapping response.
+func (m Mapping) writeResponse(w ResponseWriter, r *Request) {
+	if stream := r.HTTP2Stream(); stream != nil && m.Response.HTTP2Fault != nil {
+		m.Response.HTTP2Fault.inject(stream)
+	}
+
+	for k, v := range m.Response.Headers {
+		w.Header().Set(k, v)
+	}
+
+	switch m.Response.Encoding {
+	case "":
+	case EncodingAccepted:
+		w.CompressAccepted()
+	default:
+		w.Compress(m.Response.Encoding)
+	}
+
+	body := []byte(m.Response.Body)
+	if len(m.Response.JSONBody) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		body = m.Response.JSONBody
+	}
+
+	status := m.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.SetStatusCode(status)
+	_, _ = w.SetBodyBytes(body)
+}
+
+// ReadMappings reads all the mappings from the *.json files in dir.
+// A file can contain either a single mapping or an object with a "mappings" array.
+// Files are read in lexical order, so a later file overwrites an earlier one on the same path.
+func ReadMappings(dir string) ([]Mapping, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("filepath.Glob: %w", err)
+	}
+	sort.Strings(files)
+
+	var mappings []Mapping
+	for _, file := range files {
+		m, err := readMappingFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		mappings = append(mappings, m...)
+	}
+
+	return mappings, nil
+}
+
+func readMappingFile(file string) ([]Mapping, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	var mappings []Mapping
+	if _, ok := raw["mappings"]; ok {
+		var f mappingFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		mappings = f.Mappings
+	} else {
+		var m Mapping
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		mappings = []Mapping{m}
+	}
+
+	for i, m := range mappings {
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("mapping %d: %w", i, err)
+		}
+	}
+
+	return mappings, nil
+}
+
+// RegisterMapping registers a mapping and returns its ID.
+// Mappings registered on the same method & path are matched from the latest
+// registered one, skipping the ones whose scenario is not in the required state.
+func (s *Server) RegisterMapping(m Mapping) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+
+	// Under the lock, so the route is not removed by RemoveMapping in between.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Add the route first, it returns an error if the path conflicts with another route.
+	if err := s.router.add(m.Request.Method, s.mappingsRoute(m.Request.Method, m.Request.Path)); err != nil {
+		return "", err
+	}
+
+	return s.addMapping(m), nil
+}
+
+// addMapping stores the mapping, generating its ID if empty, and returns the ID.
+// Adding the same ID again replaces the mapping.
+// The caller must hold s.mu.
+func (s *Server) addMapping(m Mapping) string {
+	if m.ID == "" {
+		m.ID = newID()
+	}
+	s.mappings = slices.DeleteFunc(s.mappings, func(existing Mapping) bool {
+		return existing.ID == m.ID
+	})
+	s.mappings = append(s.mappings, m)
+
+	return m.ID
+}
+
+// GetMappings returns all the registered mappings.
+func (s *Server) GetMappings() []Mapping {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.mappings)
+}
+
+// GetMapping returns the mapping with the given ID.
+func (s *Server) GetMapping(id string) (Mapping, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.mappings {
+		if m.ID == id {
+			return m, true
+		}
+	}
+
+	return Mapping{}, false
+}
+
+// RemoveMapping removes the mapping with the given ID.
+// Requests to its path are answered with 404 if no other mapping matches.
+// The route of its path is unregistered with the last mapping of the method &
+// path, the path is then not found. A handler registered on the same path
+// before the mapping is not restored.
+// It returns false if there is no mapping with the ID.
+func (s *Server) RemoveMapping(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.mappings, func(m Mapping) bool {
+		return m.ID == id
+	})
+	if i < 0 {
+		return false
+	}
+	removed := s.mappings[i]
+	s.mappings = slices.Delete(s.mappings, i, i+1)
+
+	if !s.hasMapping(removed.Request.Method, removed.Request.Path) {
+		s.router.removeID(mappingsRouteID(removed.Request.Method, removed.Request.Path))
+	}
+
+	return true
+}
+
+// hasMapping returns whether a mapping is registered on the method & path.
+// The caller must hold s.mu.
+func (s *Server) hasMapping(method, path string) bool {
+	return slices.ContainsFunc(s.mappings, func(m Mapping) bool {
+		return m.Request.Method == method && m.Request.Path == path
+	})
+}
+
+// mappingsRouteID returns the ID of the route of the mappings of a method & path.
+func mappingsRouteID(method, path string) string {
+	return "mappings " + method + " " + path
+}
+
+// mappingsRoute returns the route of the mappings of a method & path.
+// Its ID identifies it to be unregistered with the mappings, unless it was replaced since.
+func (s *Server) mappingsRoute(method, path string) route {
+	return route{id: mappingsRouteID(method, path), path: path, handler: s.mappingsHandler(method, path)}
+}
+
+// LoadMappings reads the mappings in dir and registers all of them.
+func (s *Server) LoadMappings(dir string) error {
+	mappings, err := ReadMappings(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mappings {
+		if _, err := s.RegisterMapping(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mappingsHandler returns the handler that dispatches the requests of a path
+// to the matching mapping.
+func (s *Server) mappingsHandler(method, path string) ServerHandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		m, ok := s.matchMapping(method, path)
+		if !ok {
+			w.SetStatusCode(http.StatusNotFound)
+			_, _ = w.SetBodyBytes([]byte("no mapping matched"))
+			return
+		}
+
+		m.writeResponse(w, r)
+	}
+}
+
+// matchMapping finds the latest registered mapping of the path whose scenario
+// is in the required state, and moves the scenario to the new state.
+func (s *Server) matchMapping(method, path string) (Mapping, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.mappings) - 1; i >= 0; i-- {
+		m := s.mappings[i]
+		if m.Request.Method != method || m.Request.Path != path {
+			continue
+		}
+		if m.Scenario != "" && m.RequiredState != "" && s.scenarioState(m.Scenario) != m.RequiredState {
+			continue
+		}
+		if m.Scenario != "" && m.NewState != "" {
+			s.scenarios[m.Scenario] = m.NewState
+		}
+
+		return m, true
+	}
+
+	return Mapping{}, false
+}
+
+// GetScenarioState returns the current state of a scenario.
+func (s *Server) GetScenarioState(scenario string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.scenarioState(scenario)
+}
+
+// GetScenarios returns the current state of all the scenarios used by the mappings.
+func (s *Server) GetScenarios() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scenarios := map[string]string{}
+	for _, m := range s.mappings {
+		if m.Scenario != "" {
+			scenarios[m.Scenario] = s.scenarioState(m.Scenario)
+		}
+	}
+	for scenario, state := range s.scenarios {
+		scenarios[scenario] = state
+	}
+
+	return scenarios
+}
+
+// SetScenarioState sets the state of a scenario.
+func (s *Server) SetScenarioState(scenario, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scenarios[scenario] = state
+}
+
+// ResetScenarios moves all the scenarios back to ScenarioStarted.
+func (s *Server) ResetScenarios() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scenarios = map[string]string{}
+}
+
+// scenarioState returns the state of a scenario, the caller must hold s.mu.
+func (s *Server) scenarioState(scenario string) string {
+	state, ok := s.scenarios[scenario]
+	if !ok {
+		return ScenarioStarted
+	}
+
+	return state
+}
+
+// newID generates a random ID in the UUID format.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,148 @@
+package httptest_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestLoadMappings() {
+	dir := s.T().TempDir()
+	s.NoError(os.WriteFile(filepath.Join(dir, "single.json"), []byte(`{
+		"request": {"method": "GET", "path": "/users/:id"},
+		"response": {"status": 200, "headers": {"X-Some": "header"}, "jsonBody": {"name": "abcd"}}
+	}`), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(dir, "multiple.json"), []byte(`{"mappings": [
+		{"request": {"method": "POST", "path": "/users"}, "response": {"status": 201, "body": "created"}},
+		{"request": {"method": "DELETE", "path": "/users/:id"}, "response": {}}
+	]}`), 0o644))
+	// Non json files are ignored.
+	s.NoError(os.WriteFile(filepath.Join(dir, "README.md"), []byte(`# mappings`), 0o644))
+
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	s.NoError(server.LoadMappings(dir))
+
+	res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("header", res.Header.Get("X-Some"))
+	s.Equal("application/json", res.Header.Get("Content-Type"))
+	s.JSONEq(`{"name":"abcd"}`, string(resBody))
+
+	res, resBody, err = s.httpClient.Do(ctx, http.MethodPost, "/users", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, res.StatusCode)
+	s.Equal("created", string(resBody))
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodDelete, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/users/:id"))
+	s.Equal(1, server.GetNCalls(http.MethodPost, "/users"))
+	s.Equal(1, server.GetNCalls(http.MethodDelete, "/users/:id"))
+}
+
+func (s *serverTestSuite) TestLoadMappings_Invalid() {
+	dir := s.T().TempDir()
+	s.NoError(os.WriteFile(filepath.Join(dir, "invalid.json"), []byte(`{
+		"request": {"method": "GET", "path": "no-slash"},
+		"response": {}
+	}`), 0o644))
+
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	s.ErrorContains(server.LoadMappings(dir), "invalid.json")
+}
+
+func (s *serverTestSuite) TestRemoveMapping() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	first, err := server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users"},
+		Response: httptest.MappingResponse{Body: "first"},
+	})
+	s.NoError(err)
+	second, err := server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users"},
+		Response: httptest.MappingResponse{Body: "second"},
+	})
+	s.NoError(err)
+
+	// The route is kept while a mapping of the path is left.
+	s.True(server.RemoveMapping(second))
+	res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/users", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("first", string(resBody))
+
+	// The path is then not found, and its requests are not recorded.
+	s.True(server.RemoveMapping(first))
+	s.False(server.RemoveMapping(first))
+	server.ResetCalls()
+	res, resBody, err = s.httpClient.Do(ctx, http.MethodGet, "/users", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	s.NotEqual("no mapping matched", string(resBody))
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/users"))
+
+	// A handler registered after the mapping is kept.
+	id, err := server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/orders"},
+		Response: httptest.MappingResponse{Body: "mapping"},
+	})
+	s.NoError(err)
+	server.RegisterHandler(http.MethodGet, "/orders", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+	s.True(server.RemoveMapping(id))
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/orders", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusAccepted, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestRemoveMapping_Concurrent() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	// The route of a mapping registered while the last one is removed is kept.
+	for i := 0; i < 1000; i++ {
+		first, err := server.RegisterMapping(httptest.Mapping{
+			Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users"},
+			Response: httptest.MappingResponse{Body: "first"},
+		})
+		s.NoError(err)
+
+		var wg sync.WaitGroup
+		var second string
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			second, _ = server.RegisterMapping(httptest.Mapping{
+				Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users"},
+				Response: httptest.MappingResponse{Body: "second"},
+			})
+		}()
+		s.True(server.RemoveMapping(first))
+		wg.Wait()
+
+		res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/users", nil, nil, nil)
+		s.NoError(err)
+		if !s.Equal(http.StatusOK, res.StatusCode) {
+			return
+		}
+		s.Equal("second", string(resBody))
+		s.True(server.RemoveMapping(second))
+	}
+}
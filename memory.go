@@ -0,0 +1,197 @@
+package httptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// inMemoryURL is the base URL of an in-memory server.
+const inMemoryURL = "http://in-memory"
+
+// errInMemory is returned when dialing an in-memory server.
+var errInMemory = errors.New("the in-memory server has no listener, use Server.Transport")
+
+// NewInMemoryServer creates a server that does not listen: its requests are
+// sent with Server.Transport or Server.Client, and served in-process.
+// The routing, the calls and the counters are the same as a server created
+// with NewServer. The TLS, HTTP/2 & proxy settings of config are ignored, and the
+// WebSocket upgrades are not supported.
+func NewInMemoryServer(config ServerConfig) *Server {
+	setGinMode()
+
+	config.TLS, config.TLSConfig, config.ClientAuth, config.HTTP2, config.Proxy = false, nil, 0, false, false
+	server := newServer("", config, nil, nil)
+	server.httpServer.Handler = server.handler(server.engine.Handler())
+
+	return server
+}
+
+// Transport returns a transport serving the requests in-process, without
+// connecting to the server, whatever their URL.
+// The response body is streamed while the handler writes it.
+func (s *Server) Transport() http.RoundTripper {
+	return memoryTransport{handler: s.httpServer.Handler}
+}
+
+// memoryTransport serves the requests with the server handler.
+type memoryTransport struct {
+	handler http.Handler
+}
+
+func (t memoryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = "127.0.0.1:0"
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.1", 1, 1
+	// The server side request URL has no scheme nor host, Clone copied it.
+	r.URL.Scheme, r.URL.Host = "", ""
+
+	w := newMemoryResponseWriter(req)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				w.abort(fmt.Errorf("httptest: handler aborted: %v", err))
+				return
+			}
+			w.finish()
+		}()
+		t.handler.ServeHTTP(w, r)
+	}()
+
+	select {
+	case <-w.ready:
+		if w.err != nil {
+			return nil, w.err
+		}
+		return w.res, nil
+	case <-req.Context().Done():
+		// Nobody reads the body anymore, fail the writes of the handler.
+		_ = w.bodyReader.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+}
+
+// memoryResponseWriter streams the response written by the handler through a pipe.
+type memoryResponseWriter struct {
+	header http.Header
+	res    *http.Response
+	body   *io.PipeWriter
+	// bodyReader is the response body, closed if the request is canceled before the response.
+	bodyReader *io.PipeReader
+	// ready is closed when the response headers are written, or the handler aborted.
+	ready     chan struct{}
+	readyOnce sync.Once
+	err       error
+}
+
+func newMemoryResponseWriter(req *http.Request) *memoryResponseWriter {
+	pr, pw := io.Pipe()
+
+	return &memoryResponseWriter{
+		header: http.Header{},
+		res: &http.Response{
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Body:       pr,
+			Request:    req,
+		},
+		body:       pw,
+		bodyReader: pr,
+		ready:      make(chan struct{}),
+	}
+}
+
+func (w *memoryResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *memoryResponseWriter) WriteHeader(code int) {
+	// The informational responses are not forwarded.
+	if code < http.StatusOK {
+		return
+	}
+
+	w.readyOnce.Do(func() {
+		w.res.StatusCode = code
+		w.res.Status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+		w.res.Header = w.header.Clone()
+		w.res.ContentLength = -1
+		if n, err := strconv.ParseInt(w.res.Header.Get("Content-Length"), 10, 64); err == nil {
+			w.res.ContentLength = n
+		}
+		if w.res.Request.Method == http.MethodHead {
+			w.res.ContentLength = 0
+		}
+		close(w.ready)
+	})
+}
+
+func (w *memoryResponseWriter) Write(p []byte) (int, error) {
+	if w.header.Get("Content-Type") == "" && w.header.Get("Content-Encoding") == "" && len(p) > 0 {
+		w.header.Set("Content-Type", http.DetectContentType(p))
+	}
+	w.WriteHeader(http.StatusOK)
+	if w.res.Request.Method == http.MethodHead {
+		return len(p), nil
+	}
+
+	return w.body.Write(p)
+}
+
+// Flush is a no-op, the writes are not buffered.
+func (w *memoryResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Hijack is not supported, there is no connection.
+func (w *memoryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("httptest: the in-memory transport does not support hijacking")
+}
+
+// finish ends the response once the handler returned, with its trailers.
+func (w *memoryResponseWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+
+	trailer := http.Header{}
+	for _, k := range w.res.Header.Values("Trailer") {
+		for _, name := range strings.Split(k, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if v, ok := w.header[name]; ok {
+				trailer[name] = v
+			}
+		}
+	}
+	for k, v := range w.header {
+		if name, ok := strings.CutPrefix(k, http.TrailerPrefix); ok {
+			trailer[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	if len(trailer) > 0 {
+		// The client reads the trailers after the body EOF, synchronized by the pipe.
+		w.res.Trailer = trailer
+	}
+	_ = w.body.Close()
+}
+
+// abort ends the response with an error, like a broken connection.
+func (w *memoryResponseWriter) abort(err error) {
+	w.readyOnce.Do(func() {
+		w.err = err
+		close(w.ready)
+	})
+	_ = w.body.CloseWithError(io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,160 @@
+package httptest_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestInMemoryServer() {
+	server := httptest.NewInMemoryServer(httptest.ServerConfig{})
+	defer server.Close()
+	s.Equal("http://in-memory", server.URL())
+
+	server.RegisterHandler(http.MethodPost, "/users/:id", func(w httptest.ResponseWriter, r *httptest.Request) {
+		body, err := io.ReadAll(r.Body)
+		s.NoError(err)
+		w.Header().Set("X-Id", r.Params.ByName("id"))
+		w.SetStatusCode(http.StatusCreated)
+		_, _ = w.SetBodyBytes(body)
+	})
+	server.Host("api.slack.test").RegisterHandler(http.MethodPost, "/users/:id", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	client := server.Client()
+	res, err := client.Post(server.URL()+"/users/1?a=b", "text/plain", strings.NewReader("hello"))
+	s.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusCreated, res.StatusCode)
+	s.Equal("1", res.Header.Get("X-Id"))
+	s.Equal("hello", string(body))
+
+	calls := server.GetCalls(http.MethodPost, "/users/:id")
+	s.Len(calls, 1)
+	s.Equal([]byte("hello"), calls[0].Body)
+	s.Equal("b", calls[0].Query.Get("a"))
+	s.Equal(map[string]string{"id": "1"}, calls[0].Params)
+
+	// The URL host is the request host, for the virtual hosts.
+	res, err = client.Post("https://api.slack.test/users/1", "text/plain", nil)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	res, err = client.Get(server.URL() + "/unknown")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	// There is no listener to dial.
+	_, err = (&http.Client{Transport: server.HostsTransport()}).Get(server.URL())
+	s.Error(err)
+}
+
+func (s *serverTestSuite) TestTransport_Streaming() {
+	server := httptest.NewInMemoryServer(httptest.ServerConfig{})
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/events", func(w httptest.ResponseWriter, r *httptest.Request) {
+		_ = w.SetBodySSE(r, httptest.SSEStream{
+			Events: []httptest.SSEEvent{
+				{Data: "first"},
+				{Data: "second", Delay: time.Hour},
+			},
+			DisconnectAfter: 1,
+		})
+	})
+	server.RegisterHandler(http.MethodGet, "/trailers", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.SetStatusCode(http.StatusOK)
+		_, _ = w.SetBodyBytes([]byte("body"))
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Status", "done")
+	})
+
+	client := &http.Client{Transport: server.Transport()}
+
+	// The first event is received before the handler ends, then the stream is
+	// broken like a dropped connection.
+	res, err := client.Get(server.URL() + "/events")
+	s.NoError(err)
+	s.Equal("text/event-stream", res.Header.Get("Content-Type"))
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	s.NoError(err)
+	s.Equal("data:first\n", line)
+	_, err = io.ReadAll(res.Body)
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	_ = res.Body.Close()
+
+	res, err = client.Get(server.URL() + "/trailers")
+	s.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal("body", string(body))
+	s.Equal("abc", res.Trailer.Get("X-Checksum"))
+	s.Equal("done", res.Trailer.Get("X-Status"))
+}
+
+func (s *serverTestSuite) TestTransport_ContextCanceled() {
+	server := httptest.NewInMemoryServer(httptest.ServerConfig{})
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/slow", func(w httptest.ResponseWriter, r *httptest.Request) {
+		<-r.Context().Done()
+	})
+	writeErr := make(chan error, 1)
+	server.RegisterHandler(http.MethodGet, "/slow-write", func(w httptest.ResponseWriter, r *httptest.Request) {
+		<-r.Context().Done()
+		_, err := w.SetBodyBytes([]byte("too late"))
+		writeErr <- err
+	})
+
+	for _, path := range []string{"/slow", "/slow-write"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL()+path, nil)
+		s.NoError(err)
+		_, err = server.Client().Do(req)
+		s.ErrorIs(err, context.DeadlineExceeded, path)
+		cancel()
+	}
+
+	// The writes after the cancellation fail instead of blocking the handler.
+	select {
+	case err := <-writeErr:
+		s.ErrorIs(err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		s.Fail("the handler write is blocked")
+	}
+}
+
+func (s *serverTestSuite) TestTransport_TCPServer() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodPut, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+		_, _ = w.SetBodyJSON(map[string]string{"proto": r.Proto})
+	})
+
+	// The transport of a server with a listener serves in-process too.
+	req, err := http.NewRequest(http.MethodPut, "http://any.test/path", bytes.NewReader([]byte("{}")))
+	s.NoError(err)
+	res, err := (&http.Client{Transport: server.Transport()}).Do(req)
+	s.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.JSONEq(`{"proto":"HTTP/1.1"}`, string(body))
+	s.Equal(1, server.GetNCalls(http.MethodPut, "/path"))
+}
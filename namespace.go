@@ -0,0 +1,124 @@
+package httptest
+
+// namespace is a route table with its own journal of the calls.
+// The Server has its own namespace, and each Scope another one.
+// The journal is guarded by the Server mutex.
+type namespace struct {
+	router *router
+	// nCalls store map[method][path]count
+	nCalls map[string]map[string]int
+	calls  map[string]map[string][]RequestMade
+}
+
+func newNamespace() *namespace {
+	return &namespace{
+		router: newRouter(),
+		nCalls: map[string]map[string]int{},
+		calls:  map[string]map[string][]RequestMade{},
+	}
+}
+
+// register registers a route with an ID, see Server.Register.
+func (ns *namespace) register(method string, path string, handler ServerHandlerFunc, opts RouteOptions) (string, error) {
+	id := newID()
+	rt := route{id: id, path: path, priority: opts.Priority, handler: handler}
+	if err := ns.router.add(method, rt); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// initMethod initializes the maps of a method.
+func (ns *namespace) initMethod(method string) {
+	if ns.nCalls[method] == nil {
+		ns.nCalls[method] = map[string]int{}
+	}
+	if ns.calls[method] == nil {
+		ns.calls[method] = map[string][]RequestMade{}
+	}
+}
+
+// getCalls returns a copy of the calls for a path, in order.
+func (ns *namespace) getCalls(method, path string) []RequestMade {
+	return append([]RequestMade(nil), ns.calls[method][path]...)
+}
+
+// resetNCalls resets the number of nCalls for all paths.
+func (ns *namespace) resetNCalls() {
+	for method := range ns.nCalls {
+		for path := range ns.nCalls[method] {
+			ns.nCalls[method][path] = 0
+		}
+	}
+}
+
+// resetCalls resets the calls & nCalls for all paths.
+func (ns *namespace) resetCalls() {
+	ns.resetNCalls()
+	for method := range ns.calls {
+		for path := range ns.calls[method] {
+			ns.calls[method][path] = []RequestMade{}
+		}
+	}
+}
+
+// resetAll resets the routes, calls & nCalls.
+func (ns *namespace) resetAll() {
+	ns.router.reset()
+	ns.nCalls = map[string]map[string]int{}
+	ns.calls = map[string]map[string][]RequestMade{}
+}
+
+// namespaceView exposes the handlers and calls of a namespace other than the
+// Server one, see Scope and VirtualHost.
+type namespaceView struct {
+	server *Server
+	ns     *namespace
+}
+
+// RegisterHandler registers handler of a path, see Server.RegisterHandler.
+func (v namespaceView) RegisterHandler(method string, path string, handler ServerHandlerFunc) {
+	if err := v.ns.router.add(method, route{path: path, handler: handler}); err != nil {
+		panic(err)
+	}
+}
+
+// Register registers handler of a path and returns the ID of the route, see Server.Register.
+func (v namespaceView) Register(method string, path string, handler ServerHandlerFunc, opts RouteOptions) (string, error) {
+	return v.ns.register(method, path, handler, opts)
+}
+
+// Unregister removes the route registered by Register with the ID, see Server.Unregister.
+func (v namespaceView) Unregister(id string) bool {
+	return v.ns.router.removeID(id)
+}
+
+// UnregisterHandler removes the handler of a path, see Server.UnregisterHandler.
+func (v namespaceView) UnregisterHandler(method string, path string) bool {
+	return v.ns.router.remove(method, path)
+}
+
+// GetNCalls returns the number of nCalls for a path.
+func (v namespaceView) GetNCalls(method, path string) int {
+	v.server.mu.Lock()
+	defer v.server.mu.Unlock()
+
+	return v.ns.nCalls[method][path]
+}
+
+// GetCalls returns a copy of the calls for a path, in order.
+func (v namespaceView) GetCalls(method, path string) []RequestMade {
+	v.server.mu.Lock()
+	defer v.server.mu.Unlock()
+
+	return v.ns.getCalls(method, path)
+}
+
+// ResetCalls resets the calls & nCalls. It does not reset the handlers.
+func (v namespaceView) ResetCalls() {
+	v.server.mu.Lock()
+	defer v.server.mu.Unlock()
+
+	v.ns.resetCalls()
+}
@@ -0,0 +1,102 @@
+package httptest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The prefixes of the paths matched with a pattern rather than the gin path syntax.
+const (
+	regexPathPrefix = "regex:"
+	globPathPrefix  = "glob:"
+)
+
+// RegexPath returns a path matching the request paths with a regular expression,
+// to register with RegisterHandler, Register or in a Mapping, e.g.
+//
+//	server.RegisterHandler(http.MethodGet, httptest.RegexPath(`/v[0-9]+/users/(?P<name>.*)\.json`), handler)
+//
+// The expression must match the whole path, without the query string, which
+// is not routed on. Its named groups are the request params, see Params.ByName.
+// The path is also the key of the recorded calls, see Server.GetCalls.
+func RegexPath(expr string) string {
+	return regexPathPrefix + expr
+}
+
+// GlobPath returns a path matching the request paths with a glob pattern, to
+// register with RegisterHandler, Register or in a Mapping, e.g.
+//
+//	server.RegisterHandler(http.MethodGet, httptest.GlobPath("/static/**/*.js"), handler)
+//
+// `*` matches any characters except '/', `**` any characters, `**/` zero or
+// more directories, `?` one character except '/', and `[...]` a character
+// class, `[!...]` its negation. The query string is not matched.
+// The path is also the key of the recorded calls, see Server.GetCalls.
+func GlobPath(pattern string) string {
+	return globPathPrefix + pattern
+}
+
+// compilePathPattern compiles the pattern of a RegexPath or GlobPath path.
+// It returns nil for a path with the gin path syntax.
+func compilePathPattern(path string) (*regexp.Regexp, error) {
+	var expr string
+	if e, ok := strings.CutPrefix(path, regexPathPrefix); ok {
+		expr = e
+	} else if pattern, ok := strings.CutPrefix(path, globPathPrefix); ok {
+		var err error
+		if expr, err = globToRegex(pattern); err != nil {
+			return nil, fmt.Errorf("path %q: %w", path, err)
+		}
+	} else {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("path %q: regexp.Compile: %w", path, err)
+	}
+
+	return re, nil
+}
+
+// globToRegex converts a glob pattern to a regular expression.
+func globToRegex(pattern string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			switch {
+			case strings.HasPrefix(pattern[i:], "**/"):
+				// Zero or more directories, e.g. "/static/**/*.js" matches "/static/app.js".
+				b.WriteString(`(?:.*/)?`)
+				i += 2
+			case strings.HasPrefix(pattern[i:], "**"):
+				b.WriteString(`.*`)
+				i++
+			default:
+				b.WriteString(`[^/]*`)
+			}
+		case '?':
+			b.WriteString(`[^/]`)
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			if strings.IndexByte(`\.+()|]{}^$`, c) >= 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
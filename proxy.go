@@ -0,0 +1,160 @@
+package httptest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// tlsRecordHandshake is the first byte of a TLS connection, the ClientHello record type.
+const tlsRecordHandshake = 0x16
+
+// proxyHandler returns a handler answering the CONNECT requests with a tunnel
+// serving the requests with h, and the other requests with next.
+func (s *Server) proxyHandler(next, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.serveConnect(w, r, h)
+	})
+}
+
+// serveConnect hijacks the connection of a CONNECT request and serves the
+// tunnelled requests with h. A TLS tunnel is intercepted with a certificate
+// issued by the server CA for the requested host.
+func (s *Server) serveConnect(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT is only supported over HTTP/1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		s.config.Logger.Printf("httptest: hijack CONNECT %s: %v", r.Host, err)
+		return
+	}
+
+	tracked, ok := s.trackHijacked(conn)
+	if !ok {
+		_ = conn.Close()
+		return
+	}
+	tunnel := &tunnelConn{Conn: tracked, r: rw.Reader}
+
+	if _, err := rw.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		_ = tunnel.Close()
+		return
+	}
+	if err := rw.Flush(); err != nil {
+		_ = tunnel.Close()
+		return
+	}
+
+	// The tunnel is TLS if the client starts with a handshake, e.g. for https URLs.
+	first, err := rw.Reader.Peek(1)
+	if err != nil {
+		_ = tunnel.Close()
+		return
+	}
+	var c net.Conn = tunnel
+	if first[0] == tlsRecordHandshake {
+		c = tls.Server(tunnel, s.tunnelTLSConfig(r.Host))
+	}
+
+	srv := &http.Server{
+		Handler:  h,
+		ErrorLog: log.New(s.config.Logger.Writer(), s.config.Logger.Prefix(), s.config.Logger.Flags()),
+	}
+	// Serve returns once the connection is accepted, it is served in the background.
+	_ = srv.Serve(&connListener{conn: c})
+}
+
+// tunnelTLSConfig returns the TLS config intercepting a tunnel to the host,
+// e.g. "slack.com:443". The certificate is issued for the requested server
+// name, or the host if the client does not send it.
+func (s *Server) tunnelTLSConfig(host string) *tls.Config {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	ca := s.config.CA
+
+	return &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return ca.getCertificate(hello.ServerName, nil)
+			}
+			return ca.getCertificate(host, nil)
+		},
+	}
+}
+
+// ProxyURL returns the URL to use the server as a forward proxy, e.g. in the
+// HTTP_PROXY & HTTPS_PROXY environment variables, see ServerConfig.Proxy.
+func (s *Server) ProxyURL() *url.URL {
+	u, _ := url.Parse(s.URL())
+	return u
+}
+
+// ProxyTransport returns a transport sending all the requests through the
+// server as a forward proxy, and trusting the certificates of the intercepted
+// HTTPS requests, e.g.
+//
+//	server.Host("slack.com").RegisterHandler(http.MethodPost, "/api/chat.postMessage", handler)
+//	client := &http.Client{Transport: server.ProxyTransport()}
+//	client.Post("https://slack.com/api/chat.postMessage", ...)
+//
+// The https URLs require ServerConfig.Proxy.
+func (s *Server) ProxyTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(s.ProxyURL())
+	if dial := s.clientDialContext(); dial != nil {
+		transport.DialContext = dial
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: s.CertPool()}
+
+	return transport
+}
+
+// tunnelConn is the hijacked connection of a tunnel, read through the buffer
+// of the CONNECT request.
+type tunnelConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *tunnelConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connListener is a listener accepting a single connection.
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() {
+		conn = l.conn
+	})
+	if conn == nil {
+		return nil, net.ErrClosed
+	}
+
+	return conn, nil
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
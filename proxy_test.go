@@ -0,0 +1,111 @@
+package httptest_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestProxy() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{Proxy: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.Host("slack.com").RegisterHandler(http.MethodGet, "/api/users", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+		_, _ = w.SetBodyBytes([]byte(r.Host))
+	})
+	s.Equal(baseURL, server.ProxyURL().String())
+
+	client := &http.Client{Transport: server.ProxyTransport()}
+	testCases := []struct {
+		url            string
+		expectedStatus int
+	}{
+		// Absolute-form request.
+		{"http://slack.com/api/users", http.StatusAccepted},
+		// CONNECT tunnel, with TLS interception.
+		{"https://slack.com/api/users", http.StatusAccepted},
+		{"https://other.test/api/users", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		res, err := client.Get(tc.url)
+		if !s.NoError(err, tc.url) {
+			continue
+		}
+		_ = res.Body.Close()
+		s.Equal(tc.expectedStatus, res.StatusCode, tc.url)
+	}
+
+	res, err := client.Get("https://slack.com/api/users")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal("slack.com", res.TLS.PeerCertificates[0].DNSNames[0])
+
+	// CONNECT tunnel, without TLS.
+	conn, err := net.Dial("tcp", address)
+	s.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("CONNECT slack.com:80 HTTP/1.1\r\nHost: slack.com:80\r\n\r\n"))
+	s.NoError(err)
+	br := bufio.NewReader(conn)
+	res, err = http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	_, err = conn.Write([]byte("GET /api/users HTTP/1.1\r\nHost: slack.com\r\n\r\n"))
+	s.NoError(err)
+	res, err = http.ReadResponse(br, nil)
+	s.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+	s.Equal(http.StatusAccepted, res.StatusCode)
+	s.Equal("slack.com", string(body))
+
+	s.Len(server.Host("slack.com").GetCalls(http.MethodGet, "/api/users"), 4)
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/api/users"))
+
+	// Close closes the tunnels.
+	s.NoError(server.Close())
+	_, err = br.ReadByte()
+	s.ErrorIs(err, io.EOF)
+}
+
+func (s *serverTestSuite) TestProxy_TLS() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{TLS: true, Proxy: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.Host("slack.com").RegisterHandler(http.MethodGet, "/api/users", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	// The server is an HTTPS proxy, tunnelling TLS.
+	s.Equal("https", server.ProxyURL().Scheme)
+	res, err := (&http.Client{Transport: server.ProxyTransport()}).Get("https://slack.com/api/users")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestProxy_Disabled() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.Host("slack.com").RegisterHandler(http.MethodGet, "/api/users", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	// The absolute-form requests are served, not the CONNECT tunnels.
+	client := &http.Client{Transport: server.ProxyTransport()}
+	res, err := client.Get("http://slack.com/api/users")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	_, err = client.Get("https://slack.com/api/users")
+	s.Error(err)
+}
@@ -9,11 +9,53 @@ import (
 // ResponseWriter is a struct that handles the response writing.
 type ResponseWriter struct {
 	w http.ResponseWriter
+	r *http.Request
+
+	// encoding compresses the body, see Compress.
+	encoding string
+	// negotiate compresses the body with an encoding accepted by the client, see CompressAccepted.
+	negotiate bool
 }
 
 // SetBodyBytes sets the response body.
+// It is compressed if Compress or CompressAccepted was called.
 func (r *ResponseWriter) SetBodyBytes(b []byte) (int, error) {
-	return r.w.Write(b)
+	encoding := r.encoding
+	if r.negotiate {
+		r.w.Header().Add("Vary", "Accept-Encoding")
+		encoding = negotiateEncoding(r.r.Header.Values("Accept-Encoding"))
+	}
+	if encoding == "" {
+		return r.w.Write(b)
+	}
+
+	compressed, err := encodeContentEncoding(encoding, b)
+	if err != nil {
+		return 0, fmt.Errorf("compress %s body: %w", encoding, err)
+	}
+	// Keep the header set by the handler, e.g. to send a wrong one on purpose.
+	if r.w.Header().Get("Content-Encoding") == "" {
+		r.w.Header().Set("Content-Encoding", encoding)
+	}
+
+	return r.w.Write(compressed)
+}
+
+// Compress compresses the body set afterwards with the encoding: gzip, deflate, br or zstd,
+// even if the client does not accept it.
+// The Content-Encoding header is set, unless the handler already set it, e.g. to a wrong encoding.
+// Every SetBodyBytes call is compressed on its own, so the body should be set at once.
+func (r *ResponseWriter) Compress(encoding string) {
+	r.encoding = encoding
+	r.negotiate = false
+}
+
+// CompressAccepted compresses the body set afterwards with the encoding preferred
+// by the client in the Accept-Encoding header, among gzip, deflate, br and zstd.
+// The body is not compressed if the client accepts none of them.
+func (r *ResponseWriter) CompressAccepted() {
+	r.encoding = ""
+	r.negotiate = true
 }
 
 // SetBodyJSON marshals s to JSON and sets it as the response body, and
@@ -26,7 +68,7 @@ func (r *ResponseWriter) SetBodyJSON(s any) (int, error) {
 		return 0, fmt.Errorf("json.Marshal: %w", err)
 	}
 
-	return r.w.Write(b)
+	return r.SetBodyBytes(b)
 }
 
 // SetStatusCode sets the response status code.
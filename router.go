@@ -0,0 +1,494 @@
+package httptest
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// router matches the request paths against the registered routes, with the
+// gin path syntax: `:name` matches a path segment, or its rest after a static
+// prefix like in `/user_:name`, `*name` the rest of the path, or with a
+// pattern, see RegexPath and GlobPath.
+// Unlike a gin engine, its routes can be added, replaced and removed while
+// serving, in time proportional to the length of the path.
+type router struct {
+	mu sync.RWMutex
+	// trees store map[method]root node
+	trees map[string]*routeNode
+	// patterns store map[method]routes matched with a pattern, in registration order.
+	patterns map[string][]*route
+	// ids store map[route ID]route of the routes registered with an ID
+	ids map[string]methodRoute
+}
+
+// routeNode is a path segment of the routes tree.
+type routeNode struct {
+	static map[string]*routeNode
+	// param is the child matching any non-empty segment.
+	param     *routeNode
+	paramName string
+	// prefixed are the children matching the segments with a static prefix,
+	// e.g. "user_:name", by decreasing prefix length.
+	prefixed []*prefixedParam
+	// catchAll is the route matching the rest of the path.
+	catchAll     *route
+	catchAllName string
+	// route is the route ending at this node.
+	route *route
+}
+
+// prefixedParam is a child node matching the non-empty rest of the segments
+// starting with prefix.
+type prefixedParam struct {
+	prefix string
+	name   string
+	node   *routeNode
+}
+
+// route is a registered route.
+type route struct {
+	// id identifies the route, see Server.Register. It is empty for the routes
+	// registered without an ID.
+	id string
+	// path is the registered path, e.g. "/users/:id".
+	path     string
+	priority int
+	handler  ServerHandlerFunc
+	// pattern matches the request paths of a RegexPath or GlobPath path.
+	pattern *regexp.Regexp
+}
+
+// methodRoute is a route of a method.
+type methodRoute struct {
+	method string
+	route
+}
+
+func newRouter() *router {
+	return &router{trees: map[string]*routeNode{}, patterns: map[string][]*route{}, ids: map[string]methodRoute{}}
+}
+
+// add adds the route, replacing the route of the same method & path.
+// It returns an error if the path is invalid or conflicts with another route.
+func (r *router) add(method string, rt route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.addLocked(method, rt)
+
+	return err
+}
+
+// update adds the routes and removes the routes of the IDs at once: either
+// all the routes are added and removed, or nothing changes if one of them fails.
+func (r *router) update(routes []methodRoute, removeIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// replaced store the routes replaced by routes[i], nil if none.
+	var replaced []*route
+	for i, rt := range routes {
+		prev, err := r.addLocked(rt.method, rt.route)
+		if err != nil {
+			for i--; i >= 0; i-- {
+				if replaced[i] == nil {
+					r.removeLocked(routes[i].method, routes[i].path)
+				} else {
+					_, _ = r.addLocked(routes[i].method, *replaced[i])
+				}
+			}
+			return err
+		}
+		replaced = append(replaced, prev)
+	}
+	for _, id := range removeIDs {
+		if rt, ok := r.ids[id]; ok {
+			r.removeLocked(rt.method, rt.path)
+		}
+	}
+
+	return nil
+}
+
+// addLocked adds the route and returns the route it replaced, if any.
+// The caller must hold r.mu.
+func (r *router) addLocked(method string, rt route) (*route, error) {
+	pattern, err := compilePathPattern(rt.path)
+	if err != nil {
+		return nil, err
+	}
+	if pattern != nil {
+		rt.pattern = pattern
+		return r.addPatternLocked(method, rt), nil
+	}
+
+	path := rt.path
+	segments, err := splitRoutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the conflicts first, so a failed add leaves the tree unchanged.
+	n := r.trees[method]
+	for _, seg := range segments {
+		if n == nil {
+			break
+		}
+		switch wildcard(seg) {
+		case ':':
+			prefix, name := splitParam(seg)
+			if prefix != "" {
+				p := n.prefixedParam(prefix)
+				if p != nil && p.name != name {
+					return nil, fmt.Errorf("path %q: wildcard %q conflicts with existing wildcard '%s:%s'", path, seg, prefix, p.name)
+				}
+				n = nil
+				if p != nil {
+					n = p.node
+				}
+				continue
+			}
+			if n.param != nil && n.paramName != name {
+				return nil, fmt.Errorf("path %q: wildcard %q conflicts with existing wildcard ':%s'", path, seg, n.paramName)
+			}
+			n = n.param
+		case '*':
+			if n.catchAll != nil && n.catchAllName != seg[1:] {
+				return nil, fmt.Errorf("path %q: wildcard %q conflicts with existing wildcard '*%s'", path, seg, n.catchAllName)
+			}
+			n = nil
+		default:
+			n = n.static[seg]
+		}
+	}
+
+	if r.trees[method] == nil {
+		r.trees[method] = &routeNode{}
+	}
+	n = r.trees[method]
+	for _, seg := range segments {
+		switch wildcard(seg) {
+		case ':':
+			prefix, name := splitParam(seg)
+			if prefix != "" {
+				n = n.addPrefixedParam(prefix, name)
+				continue
+			}
+			if n.param == nil {
+				n.param, n.paramName = &routeNode{}, name
+			}
+			n = n.param
+		case '*':
+			prev := n.catchAll
+			n.catchAll, n.catchAllName = &rt, seg[1:]
+			r.replaceID(method, prev, &rt)
+			return prev, nil
+		default:
+			child := n.static[seg]
+			if child == nil {
+				if n.static == nil {
+					n.static = map[string]*routeNode{}
+				}
+				child = &routeNode{}
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+
+	prev := n.route
+	n.route = &rt
+	r.replaceID(method, prev, &rt)
+
+	return prev, nil
+}
+
+// addPatternLocked adds a route matched with a pattern, and returns the route it replaced, if any.
+// The caller must hold r.mu.
+func (r *router) addPatternLocked(method string, rt route) *route {
+	routes := r.patterns[method]
+	for i, prev := range routes {
+		if prev.path == rt.path {
+			routes[i] = &rt
+			r.replaceID(method, prev, &rt)
+			return prev
+		}
+	}
+	r.patterns[method] = append(routes, &rt)
+	r.replaceID(method, nil, &rt)
+
+	return nil
+}
+
+// replaceID replaces the ID of the replaced route, if any, by the one of the new route.
+// The caller must hold r.mu.
+func (r *router) replaceID(method string, prev, rt *route) {
+	if prev != nil && prev.id != "" {
+		delete(r.ids, prev.id)
+	}
+	if rt != nil && rt.id != "" {
+		r.ids[rt.id] = methodRoute{method: method, route: *rt}
+	}
+}
+
+// remove removes the route of the method & path. It returns false if the route
+// is not registered.
+func (r *router) remove(method, path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.removeLocked(method, path)
+}
+
+// removeLocked removes the route, pruning the nodes left empty.
+// The caller must hold r.mu.
+func (r *router) removeLocked(method, path string) bool {
+	for i, rt := range r.patterns[method] {
+		if rt.path == path {
+			r.patterns[method] = slices.Delete(r.patterns[method], i, i+1)
+			r.replaceID(method, rt, nil)
+			return true
+		}
+	}
+
+	segments, err := splitRoutePath(path)
+	if err != nil || r.trees[method] == nil {
+		return false
+	}
+
+	removed := r.trees[method].remove(segments)
+	if r.trees[method].empty() {
+		delete(r.trees, method)
+	}
+	r.replaceID(method, removed, nil)
+
+	return removed != nil
+}
+
+// removeID removes the route registered with the ID. It returns false if the
+// route is not registered, or was replaced since.
+func (r *router) removeID(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rt, ok := r.ids[id]
+	if !ok {
+		return false
+	}
+
+	return r.removeLocked(rt.method, rt.path)
+}
+
+// remove removes the route and returns it, nil if not found.
+func (n *routeNode) remove(segments []string) *route {
+	if len(segments) == 0 {
+		removed := n.route
+		n.route = nil
+		return removed
+	}
+
+	var removed *route
+	seg := segments[0]
+	switch wildcard(seg) {
+	case ':':
+		prefix, name := splitParam(seg)
+		if prefix != "" {
+			i := slices.IndexFunc(n.prefixed, func(p *prefixedParam) bool { return p.prefix == prefix })
+			if i < 0 || n.prefixed[i].name != name {
+				return nil
+			}
+			if removed = n.prefixed[i].node.remove(segments[1:]); n.prefixed[i].node.empty() {
+				n.prefixed = slices.Delete(n.prefixed, i, i+1)
+			}
+			return removed
+		}
+		if n.param == nil || n.paramName != name {
+			return nil
+		}
+		if removed = n.param.remove(segments[1:]); n.param.empty() {
+			n.param, n.paramName = nil, ""
+		}
+	case '*':
+		if n.catchAllName != seg[1:] {
+			return nil
+		}
+		removed = n.catchAll
+		n.catchAll, n.catchAllName = nil, ""
+	default:
+		child := n.static[seg]
+		if child == nil {
+			return nil
+		}
+		if removed = child.remove(segments[1:]); child.empty() {
+			delete(n.static, seg)
+		}
+	}
+
+	return removed
+}
+
+func (n *routeNode) empty() bool {
+	return n.route == nil && n.catchAll == nil && n.param == nil && len(n.static) == 0 && len(n.prefixed) == 0
+}
+
+// prefixedParam returns the child of the segments starting with prefix, nil if none.
+func (n *routeNode) prefixedParam(prefix string) *prefixedParam {
+	for _, p := range n.prefixed {
+		if p.prefix == prefix {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// addPrefixedParam returns the child of the segments starting with prefix,
+// adding it if needed. The longer prefixes are kept first, as more specific.
+func (n *routeNode) addPrefixedParam(prefix, name string) *routeNode {
+	if p := n.prefixedParam(prefix); p != nil {
+		return p.node
+	}
+
+	p := &prefixedParam{prefix: prefix, name: name, node: &routeNode{}}
+	i, _ := slices.BinarySearchFunc(n.prefixed, len(prefix), func(p *prefixedParam, l int) int {
+		return l - len(p.prefix)
+	})
+	n.prefixed = slices.Insert(n.prefixed, i, p)
+
+	return p.node
+}
+
+// lookup finds the route of a request path, and its params.
+// The route with the highest priority matches. Among the routes of the same
+// priority, static segments win over params with a static prefix, params
+// with a static prefix over params, params over catch-alls, and
+// catch-alls over patterns, matched in registration order.
+func (r *router) lookup(method, path string) (*route, gin.Params) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best routeMatch
+	if root := r.trees[method]; root != nil && strings.HasPrefix(path, "/") {
+		root.match(strings.Split(path[1:], "/"), nil, &best)
+	}
+	for _, rt := range r.patterns[method] {
+		if best.route != nil && rt.priority <= best.route.priority {
+			continue
+		}
+		submatches := rt.pattern.FindStringSubmatch(path)
+		if submatches == nil {
+			continue
+		}
+		var params gin.Params
+		for i, name := range rt.pattern.SubexpNames() {
+			if name != "" {
+				params = append(params, gin.Param{Key: name, Value: submatches[i]})
+			}
+		}
+		best.offer(rt, params)
+	}
+
+	return best.route, best.params
+}
+
+// routeMatch is a route matching a request path.
+type routeMatch struct {
+	route  *route
+	params gin.Params
+}
+
+// match visits the routes matching the segments by order of specificity, and
+// keeps in best the first one with the highest priority.
+func (n *routeNode) match(segments []string, params gin.Params, best *routeMatch) {
+	if len(segments) == 0 {
+		best.offer(n.route, params)
+		return
+	}
+
+	seg := segments[0]
+	if child := n.static[seg]; child != nil {
+		child.match(segments[1:], params, best)
+	}
+	for _, p := range n.prefixed {
+		if len(seg) > len(p.prefix) && strings.HasPrefix(seg, p.prefix) {
+			p.node.match(segments[1:], append(params, gin.Param{Key: p.name, Value: seg[len(p.prefix):]}), best)
+		}
+	}
+	if n.param != nil && seg != "" {
+		n.param.match(segments[1:], append(params, gin.Param{Key: n.paramName, Value: seg}), best)
+	}
+	if n.catchAll != nil {
+		best.offer(n.catchAll, append(params, gin.Param{Key: n.catchAllName, Value: "/" + strings.Join(segments, "/")}))
+	}
+}
+
+// offer keeps the route if it has a higher priority than the best one.
+func (m *routeMatch) offer(rt *route, params gin.Params) {
+	if rt == nil || (m.route != nil && rt.priority <= m.route.priority) {
+		return
+	}
+
+	// params is reused by the next visited routes.
+	m.route, m.params = rt, append(gin.Params(nil), params...)
+}
+
+// reset removes all the routes.
+func (r *router) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.trees = map[string]*routeNode{}
+	r.patterns = map[string][]*route{}
+	r.ids = map[string]methodRoute{}
+}
+
+// wildcard returns the wildcard character of the segment, or 0 for a static segment.
+// A param may follow a static prefix, e.g. "user_:name", not a catch-all.
+func wildcard(seg string) byte {
+	if i := strings.IndexAny(seg, ":*"); i >= 0 {
+		return seg[i]
+	}
+
+	return 0
+}
+
+// splitParam splits a param segment into its static prefix and the param name,
+// e.g. "user_" & "name" for "user_:name".
+func splitParam(seg string) (string, string) {
+	i := strings.IndexByte(seg, ':')
+	return seg[:i], seg[i+1:]
+}
+
+// splitRoutePath splits a registered path into its segments, validating the wildcards.
+func splitRoutePath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q must begin with '/'", path)
+	}
+
+	segments := strings.Split(path[1:], "/")
+	for i, seg := range segments {
+		w := strings.IndexAny(seg, ":*")
+		if w < 0 {
+			continue
+		}
+		if strings.ContainsAny(seg[w+1:], ":*") {
+			return nil, fmt.Errorf("path %q: only one wildcard per path segment is allowed", path)
+		}
+		if w == len(seg)-1 {
+			return nil, fmt.Errorf("path %q: wildcards must be named with a non-empty name", path)
+		}
+		if seg[w] == '*' && w > 0 {
+			return nil, fmt.Errorf("path %q: catch-all wildcards must be a whole path segment", path)
+		}
+		if seg[w] == '*' && i != len(segments)-1 {
+			return nil, fmt.Errorf("path %q: catch-all routes are only allowed at the end of the path", path)
+		}
+	}
+
+	return segments, nil
+}
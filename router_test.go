@@ -0,0 +1,381 @@
+package httptest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestUnregisterHandler() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/users/:id", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+	server.RegisterHandler(http.MethodGet, "/users/:id/orders", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	s.True(server.UnregisterHandler(http.MethodGet, "/users/:id"))
+	s.False(server.UnregisterHandler(http.MethodGet, "/users/:id"))
+	s.False(server.UnregisterHandler(http.MethodPost, "/users/:id/orders"))
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	// The other routes and the calls are kept.
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users/1/orders", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusAccepted, res.StatusCode)
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/users/:id"))
+
+	// The path can be registered again.
+	server.RegisterHandler(http.MethodGet, "/users/:id", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusCreated)
+	})
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestRouting() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(name string) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(http.StatusOK)
+			_, _ = w.SetBodyJSON(map[string]any{"route": name, "params": r.Params.All()})
+		}
+	}
+	server.RegisterHandler(http.MethodGet, "/files/*filepath", respond("catch-all"))
+	server.RegisterHandler(http.MethodGet, "/files/:name/raw", respond("param"))
+	server.RegisterHandler(http.MethodGet, "/files/readme/raw", respond("static"))
+	server.RegisterHandler(http.MethodGet, "/dir/", respond("dir"))
+
+	testCases := []struct {
+		path         string
+		expectedBody string
+	}{
+		{"/files/readme/raw", `{"params":{},"route":"static"}`},
+		{"/files/main.go/raw", `{"params":{"name":"main.go"},"route":"param"}`},
+		// The static and param routes do not match, backtrack to the catch-all.
+		{"/files/readme/raw/more", `{"params":{"filepath":"/readme/raw/more"},"route":"catch-all"}`},
+		{"/files/", `{"params":{"filepath":"/"},"route":"catch-all"}`},
+	}
+	for _, tc := range testCases {
+		res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, tc.path, nil, nil, nil)
+		s.NoError(err)
+		s.Equal(http.StatusOK, res.StatusCode, tc.path)
+		s.JSONEq(tc.expectedBody, string(resBody), tc.path)
+	}
+
+	// The trailing slash is redirected like gin does.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(baseURL + "/dir?a=b")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusMovedPermanently, res.StatusCode)
+	s.Equal("/dir/?a=b", res.Header.Get("Location"))
+}
+
+func (s *serverTestSuite) TestRouting_PrefixedParam() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(name string) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(http.StatusOK)
+			_, _ = w.SetBodyJSON(map[string]any{"route": name, "params": r.Params.All()})
+		}
+	}
+	// A param may follow a static prefix in a segment, like with gin.
+	server.RegisterHandler(http.MethodGet, "/user_:name", respond("user"))
+	server.RegisterHandler(http.MethodGet, "/user_admin", respond("admin"))
+	server.RegisterHandler(http.MethodGet, "/:page", respond("page"))
+	server.RegisterHandler(http.MethodPost, "/v1/users:batchGet", respond("batchGet"))
+	server.RegisterHandler(http.MethodPost, "/v1/users/:id", respond("user"))
+
+	testCases := []struct {
+		method       string
+		path         string
+		expectedBody string
+	}{
+		{http.MethodGet, "/user_john", `{"params":{"name":"john"},"route":"user"}`},
+		{http.MethodGet, "/user_admin", `{"params":{},"route":"admin"}`},
+		// The param is not empty.
+		{http.MethodGet, "/user_", `{"params":{"page":"user_"},"route":"page"}`},
+		{http.MethodPost, "/v1/users:batchGet", `{"params":{"batchGet":":batchGet"},"route":"batchGet"}`},
+		{http.MethodPost, "/v1/users/1", `{"params":{"id":"1"},"route":"user"}`},
+	}
+	for _, tc := range testCases {
+		res, resBody, err := s.httpClient.Do(ctx, tc.method, tc.path, nil, nil, nil)
+		s.NoError(err)
+		s.Equal(http.StatusOK, res.StatusCode, tc.path)
+		s.JSONEq(tc.expectedBody, string(resBody), tc.path)
+	}
+
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/user_:name"))
+	s.True(server.UnregisterHandler(http.MethodGet, "/user_:name"))
+	res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/user_john", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.JSONEq(`{"params":{"page":"user_john"},"route":"page"}`, string(resBody))
+}
+
+func (s *serverTestSuite) TestRegisterHandler_InvalidPath() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	handler := func(w httptest.ResponseWriter, r *httptest.Request) {}
+	server.RegisterHandler(http.MethodGet, "/users/:id", handler)
+
+	s.PanicsWithError(`path "/users/:name/orders": wildcard ":name" conflicts with existing wildcard ':id'`, func() {
+		server.RegisterHandler(http.MethodGet, "/users/:name/orders", handler)
+	})
+	s.Panics(func() { server.RegisterHandler(http.MethodGet, "users", handler) })
+	s.Panics(func() { server.RegisterHandler(http.MethodGet, "/files/*path/raw", handler) })
+	s.Panics(func() { server.RegisterHandler(http.MethodGet, "/users/:", handler) })
+	s.Panics(func() { server.RegisterHandler(http.MethodGet, "/users/:id:name", handler) })
+	s.Panics(func() { server.RegisterHandler(http.MethodGet, "/files/raw*path", handler) })
+
+	server.RegisterHandler(http.MethodGet, "/user_:id", handler)
+	s.PanicsWithError(`path "/user_:name": wildcard "user_:name" conflicts with existing wildcard 'user_:id'`, func() {
+		server.RegisterHandler(http.MethodGet, "/user_:name", handler)
+	})
+}
+
+func (s *serverTestSuite) TestRegister_Priority() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(status int) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(status)
+		}
+	}
+	// The catch-all coexists with the specific routes, which win by default.
+	_, err = server.Register(http.MethodGet, "/*path", respond(http.StatusTeapot), httptest.RouteOptions{})
+	s.NoError(err)
+	_, err = server.Register(http.MethodGet, "/users/:id", respond(http.StatusOK), httptest.RouteOptions{})
+	s.NoError(err)
+	_, err = server.Register(http.MethodGet, "/users/me", respond(http.StatusAccepted), httptest.RouteOptions{})
+	s.NoError(err)
+
+	assertStatus := func(path string, expected int) {
+		res, _, err := s.httpClient.Do(ctx, http.MethodGet, path, nil, nil, nil)
+		s.NoError(err)
+		s.Equal(expected, res.StatusCode, path)
+	}
+	assertStatus("/users/1", http.StatusOK)
+	assertStatus("/users/me", http.StatusAccepted)
+	assertStatus("/orders/1", http.StatusTeapot)
+
+	// A higher priority wins over a more specific route.
+	id, err := server.Register(http.MethodGet, "/users/*rest", respond(http.StatusForbidden), httptest.RouteOptions{Priority: 1})
+	s.NoError(err)
+	assertStatus("/users/1", http.StatusForbidden)
+	assertStatus("/users/me", http.StatusForbidden)
+	assertStatus("/orders/1", http.StatusTeapot)
+
+	s.True(server.Unregister(id))
+	assertStatus("/users/1", http.StatusOK)
+}
+
+func (s *serverTestSuite) TestUnregister() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	handler := func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	}
+	id, err := server.Register(http.MethodGet, "/path", handler, httptest.RouteOptions{})
+	s.NoError(err)
+	s.NotEmpty(id)
+
+	s.True(server.Unregister(id))
+	s.False(server.Unregister(id))
+	s.False(server.Unregister("unknown"))
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/path", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	// The ID of a replaced route is not valid anymore.
+	id, err = server.Register(http.MethodGet, "/path", handler, httptest.RouteOptions{})
+	s.NoError(err)
+	server.RegisterHandler(http.MethodGet, "/path", handler)
+	s.False(server.Unregister(id))
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/path", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestRegister_Conflict() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	handler := func(w httptest.ResponseWriter, r *httptest.Request) {}
+	_, err = server.Register(http.MethodGet, "/users/:id", handler, httptest.RouteOptions{})
+	s.NoError(err)
+	_, err = server.Register(http.MethodGet, "/files/*path", handler, httptest.RouteOptions{})
+	s.NoError(err)
+
+	_, err = server.Register(http.MethodGet, "/users/:name", handler, httptest.RouteOptions{})
+	s.EqualError(err, `path "/users/:name": wildcard ":name" conflicts with existing wildcard ':id'`)
+	_, err = server.Register(http.MethodGet, "/files/*name", handler, httptest.RouteOptions{})
+	s.EqualError(err, `path "/files/*name": wildcard "*name" conflicts with existing wildcard '*path'`)
+
+	// The same wildcards of another method do not conflict.
+	_, err = server.Register(http.MethodPost, "/users/:name", handler, httptest.RouteOptions{})
+	s.NoError(err)
+
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: "/users/:name/orders"},
+		Response: httptest.MappingResponse{Status: http.StatusOK},
+	})
+	s.Error(err)
+	s.Empty(server.GetMappings())
+}
+
+func (s *serverTestSuite) TestRegexPath() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	path := httptest.RegexPath(`/v(?P<version>[0-9]+)/users/(?P<name>.*)\.json`)
+	server.RegisterHandler(http.MethodGet, path, func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.Equal("alice", r.Params.ByName("name"))
+		w.SetStatusCode(http.StatusOK)
+		_, _ = w.SetBodyJSON(r.Params.All())
+	})
+	server.RegisterHandler(http.MethodGet, "/v1/users/:name", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	res, resBody, err := s.httpClient.Do(ctx, http.MethodGet, "/v2/users/alice.json", nil, nil, url.Values{"a": {"b"}})
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.JSONEq(`{"version":"2","name":"alice"}`, string(resBody))
+
+	// The gin route is more specific than the pattern.
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/v1/users/alice.json", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	// The expression matches the whole path.
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/v2/users/alice.json/more", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	calls := server.GetCalls(http.MethodGet, path)
+	s.Len(calls, 1)
+	s.Equal(map[string]string{"version": "2", "name": "alice"}, calls[0].Params)
+
+	s.True(server.UnregisterHandler(http.MethodGet, path))
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/v2/users/alice.json", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	// The mappings support the patterns too.
+	_, err = server.RegisterMapping(httptest.Mapping{
+		Request:  httptest.MappingRequest{Method: http.MethodGet, Path: `regex:/v[0-9]+/orders`},
+		Response: httptest.MappingResponse{Status: http.StatusCreated},
+	})
+	s.NoError(err)
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/v3/orders", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, res.StatusCode)
+
+	_, err = server.Register(http.MethodGet, httptest.RegexPath(`/(unclosed`), func(w httptest.ResponseWriter, r *httptest.Request) {}, httptest.RouteOptions{})
+	s.ErrorContains(err, "regexp.Compile")
+}
+
+func (s *serverTestSuite) TestGlobPath() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(status int) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(status)
+		}
+	}
+	server.RegisterHandler(http.MethodGet, httptest.GlobPath("/static/*.js"), respond(http.StatusOK))
+	server.RegisterHandler(http.MethodGet, httptest.GlobPath("/assets/**/v?/[!_]*.css"), respond(http.StatusAccepted))
+	server.RegisterHandler(http.MethodGet, httptest.GlobPath("/lib/**/*.js"), respond(http.StatusCreated))
+	_, err = server.Register(http.MethodGet, httptest.GlobPath("/static/vendor.*"), respond(http.StatusTeapot), httptest.RouteOptions{Priority: 1})
+	s.NoError(err)
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/static/app.js", http.StatusOK},
+		{"/static/lib/app.js", http.StatusNotFound},
+		{"/static/app.jsx", http.StatusNotFound},
+		{"/static/vendor.js", http.StatusTeapot},
+		{"/assets/a/b/v1/main.css", http.StatusAccepted},
+		{"/assets/a/v1/_main.css", http.StatusNotFound},
+		{"/assets/a/v10/main.css", http.StatusNotFound},
+		// `**/` matches zero or more directories.
+		{"/lib/app.js", http.StatusCreated},
+		{"/lib/a/b/app.js", http.StatusCreated},
+		{"/lib/a/app.css", http.StatusNotFound},
+		{"/assets/v1/main.css", http.StatusAccepted},
+		// The query string is not matched.
+		{"/lib/app.js?v=1", http.StatusCreated},
+	}
+	for _, tc := range testCases {
+		res, _, err := s.httpClient.Do(ctx, http.MethodGet, tc.path, nil, nil, nil)
+		s.NoError(err)
+		s.Equal(tc.expectedStatus, res.StatusCode, tc.path)
+	}
+	s.Equal(1, server.GetNCalls(http.MethodGet, httptest.GlobPath("/static/*.js")))
+
+	_, err = server.Register(http.MethodGet, httptest.GlobPath("/[unclosed"), respond(http.StatusOK), httptest.RouteOptions{})
+	s.ErrorContains(err, "unterminated character class")
+}
+
+// BenchmarkRegisterHandler re-registers a path among a growing number of routes,
+// the cost stays flat.
+func BenchmarkRegisterHandler(b *testing.B) {
+	handler := func(w httptest.ResponseWriter, r *httptest.Request) {}
+	for _, routes := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("routes=%d", routes), func(b *testing.B) {
+			server, err := httptest.NewServer(address, httptest.ServerConfig{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer server.Close()
+
+			for i := 0; i < routes; i++ {
+				server.RegisterHandler(http.MethodGet, fmt.Sprintf("/routes/%d/:id", i), handler)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				server.RegisterHandler(http.MethodGet, fmt.Sprintf("/routes/%d/:id", i%routes), handler)
+			}
+		})
+	}
+}
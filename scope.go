@@ -0,0 +1,120 @@
+package httptest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// ScopeHeader is the request header selecting the scope of a request, see Server.Scope.
+const ScopeHeader = "X-Httptest-Scope"
+
+// ScopePathPrefix is the path prefix selecting the scope of a request,
+// followed by the scope ID, e.g. "/__scope/TestUsers-1/users/1".
+const ScopePathPrefix = "/__scope/"
+
+// Scope is an isolated view of a shared Server, with its own handlers and calls.
+// The requests are in the scope if their path has the scope prefix, see Scope.URL,
+// or if they have the ScopeHeader header with the scope ID, see Scope.Header.
+// The other requests are served by the Server handlers.
+//
+// The Server ResetCalls & ResetAll do not reset the scopes.
+type Scope struct {
+	namespaceView
+	id string
+}
+
+// Scope creates a scope for the test, removed when the test and its subtests complete.
+// Each parallel test can use its own scope of the same Server:
+//
+//	scope := server.Scope(t)
+//	scope.RegisterHandler(http.MethodGet, "/users/:id", handler)
+//	client := NewClient(scope.URL())
+func (s *Server) Scope(t testing.TB) *Scope {
+	s.mu.Lock()
+	s.scopeSeq++
+	id := fmt.Sprintf("%s-%d", scopeName(t.Name()), s.scopeSeq)
+	ns := newNamespace()
+	s.scopes[id] = ns
+	s.mu.Unlock()
+
+	t.Cleanup(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.scopes, id)
+	})
+
+	return &Scope{namespaceView: namespaceView{server: s, ns: ns}, id: id}
+}
+
+// ID returns the scope ID, unique in the Server.
+func (sc *Scope) ID() string {
+	return sc.id
+}
+
+// URL returns the base URL of the scope, e.g. "http://127.0.0.1:3010/__scope/TestUsers-1".
+func (sc *Scope) URL() string {
+	return sc.server.URL() + ScopePathPrefix + sc.id
+}
+
+// Header returns the header to add to the requests of the scope.
+func (sc *Scope) Header() http.Header {
+	return http.Header{ScopeHeader: {sc.id}}
+}
+
+// Client returns a client of the Server, see Server.Client, adding the scope
+// header to the requests.
+func (sc *Scope) Client() *http.Client {
+	client := sc.server.Client()
+	client.Transport = scopeTransport{base: client.Transport, id: sc.id}
+
+	return client
+}
+
+// requestNamespace returns the namespace of the request, and the scope path
+// prefix to strip from its path. It returns false for an unknown scope.
+// The scope path prefix has priority over the scope header, and the scopes
+// over the virtual hosts.
+func (s *Server) requestNamespace(r *http.Request) (ns *namespace, prefix string, ok bool) {
+	id := r.Header.Get(ScopeHeader)
+	if rest, found := strings.CutPrefix(r.URL.Path, ScopePathPrefix); found {
+		id, _, _ = strings.Cut(rest, "/")
+		prefix = ScopePathPrefix + id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == "" {
+		return s.hostNamespace(r), "", true
+	}
+	ns, ok = s.scopes[id]
+
+	return ns, prefix, ok
+}
+
+// scopeName replaces the characters of a test name not allowed in a scope ID.
+func scopeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// scopeTransport adds the scope header to the requests.
+type scopeTransport struct {
+	base http.RoundTripper
+	id   string
+}
+
+func (t scopeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set(ScopeHeader, t.id)
+
+	return t.base.RoundTrip(r)
+}
@@ -0,0 +1,136 @@
+package httptest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestScope_Isolation() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(status int) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(status)
+		}
+	}
+	server.RegisterHandler(http.MethodGet, "/users/:id", respond(http.StatusOK))
+	scope := server.Scope(s.T())
+	scope.RegisterHandler(http.MethodGet, "/users/:id", respond(http.StatusAccepted))
+
+	// The scope header selects the scope.
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/users/1", map[string]string{httptest.ScopeHeader: scope.ID()}, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	// The scope path prefix too, and is stripped from the path.
+	var path string
+	scope.RegisterHandler(http.MethodGet, "/orders", func(w httptest.ResponseWriter, r *httptest.Request) {
+		path = r.URL.Path
+		w.SetStatusCode(http.StatusAccepted)
+	})
+	res, err = http.Get(scope.URL() + "/orders")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+	s.Equal("/orders", path)
+
+	// The scope routes are not served out of the scope.
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/orders", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users/1", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	// The calls are recorded per scope.
+	s.Equal(1, scope.GetNCalls(http.MethodGet, "/users/:id"))
+	s.Len(scope.GetCalls(http.MethodGet, "/orders"), 1)
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/users/:id"))
+	// The request not found out of the scope is not recorded.
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/orders"))
+
+	// The server resets do not reset the scope.
+	server.ResetAll()
+	s.Equal(1, scope.GetNCalls(http.MethodGet, "/users/:id"))
+	res, err = scope.Client().Get(baseURL + "/users/1")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	scope.ResetCalls()
+	s.Equal(0, scope.GetNCalls(http.MethodGet, "/users/:id"))
+}
+
+func (s *serverTestSuite) TestScope_UnknownScope() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/path", map[string]string{httptest.ScopeHeader: "unknown"}, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, httptest.ScopePathPrefix+"unknown/path", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestScope_ParallelTests() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var scopeURLs []string
+	s.T().Run("group", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+				t.Parallel()
+
+				scope := server.Scope(t)
+				mu.Lock()
+				scopeURLs = append(scopeURLs, scope.URL())
+				mu.Unlock()
+				body := fmt.Sprint(i)
+				scope.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+					w.SetStatusCode(http.StatusOK)
+					_, _ = w.SetBodyBytes([]byte(body))
+				})
+
+				for j := 0; j < 10; j++ {
+					res, err := http.Get(scope.URL() + "/path")
+					require.NoError(t, err)
+					resBody, err := io.ReadAll(res.Body)
+					_ = res.Body.Close()
+					require.NoError(t, err)
+					assert.Equal(t, body, string(resBody))
+				}
+				assert.Equal(t, 10, scope.GetNCalls(http.MethodGet, "/path"))
+			})
+		}
+	})
+
+	// The scopes are removed when their test completes.
+	s.Len(scopeURLs, 10)
+	for _, u := range scopeURLs {
+		res, err := http.Get(u + "/path")
+		s.NoError(err)
+		_ = res.Body.Close()
+		s.Equal(http.StatusNotFound, res.StatusCode)
+	}
+}
@@ -0,0 +1,91 @@
+package httptest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+)
+
+// SSEEvent is an event of a Server-Sent Events stream.
+type SSEEvent struct {
+	ID    string
+	Event string
+	// Data is sent as is, a multiline data is sent as multiple data fields.
+	Data string
+	// Retry is the reconnection time sent to the client, not sent if zero.
+	Retry time.Duration
+	// Delay is waited before sending the event.
+	Delay time.Duration
+}
+
+// SSEStream is a Server-Sent Events stream.
+type SSEStream struct {
+	Events []SSEEvent
+	// DisconnectAfter, if set, drops the connection abruptly after that many
+	// events are sent, without ending the response. It counts the events sent
+	// by this response, i.e. after the resumed event.
+	DisconnectAfter int
+}
+
+// SetBodySSE writes the stream as a Server-Sent Events response, flushing every event.
+// If the request has a Last-Event-ID header matching an event ID, the stream is
+// resumed after that event, otherwise it is sent from the start.
+//
+// It returns when all the events are sent, or with an error when the client
+// goes away. With DisconnectAfter, it aborts the handler by panicking with
+// http.ErrAbortHandler, so it does not return.
+func (r *ResponseWriter) SetBodySSE(req *Request, stream SSEStream) error {
+	sse.Event{}.WriteContentType(r.w)
+
+	events := stream.Events
+	if lastEventID := req.Header.Get("Last-Event-ID"); lastEventID != "" {
+		for i, e := range events {
+			if e.ID == lastEventID {
+				events = events[i+1:]
+				break
+			}
+		}
+	}
+
+	disconnect := stream.DisconnectAfter > 0 && stream.DisconnectAfter <= len(events)
+	if disconnect {
+		events = events[:stream.DisconnectAfter]
+	}
+
+	flusher, _ := r.w.(http.Flusher)
+	// Send the headers right away, the first event may be delayed.
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for _, e := range events {
+		if e.Delay > 0 {
+			select {
+			case <-time.After(e.Delay):
+			case <-req.Context().Done():
+				return req.Context().Err()
+			}
+		}
+
+		err := sse.Encode(r.w, sse.Event{
+			Id:    e.ID,
+			Event: e.Event,
+			Retry: uint(e.Retry.Milliseconds()),
+			Data:  e.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("sse.Encode: %w", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if disconnect {
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
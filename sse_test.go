@@ -0,0 +1,134 @@
+package httptest_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+var sseEvents = []httptest.SSEEvent{
+	{ID: "1", Event: "greeting", Data: "hello", Retry: 2 * time.Second},
+	{ID: "2", Data: "multi\nline"},
+	{ID: "3", Event: "bye", Data: "bye"},
+}
+
+func (s *serverTestSuite) TestSetBodySSE() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/events", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.NoError(w.SetBodySSE(r, httptest.SSEStream{Events: sseEvents}))
+	})
+
+	res, err := http.Get(baseURL + "/events")
+	s.NoError(err)
+	defer res.Body.Close()
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("text/event-stream", res.Header.Get("Content-Type"))
+	s.Equal("no-cache", res.Header.Get("Cache-Control"))
+
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+	s.Equal(
+		"id:1\nevent:greeting\nretry:2000\ndata:hello\n\n"+
+			"id:2\ndata:multi\ndata:line\n\n"+
+			"id:3\nevent:bye\ndata:bye\n\n",
+		string(body),
+	)
+}
+
+func (s *serverTestSuite) TestSetBodySSE_Resume() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/events", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.NoError(w.SetBodySSE(r, httptest.SSEStream{Events: sseEvents}))
+	})
+
+	testCases := []struct {
+		name         string
+		lastEventID  string
+		expectedBody string
+	}{
+		{
+			name:         "resumes after the last event",
+			lastEventID:  "2",
+			expectedBody: "id:3\nevent:bye\ndata:bye\n\n",
+		},
+		{
+			name:         "nothing left",
+			lastEventID:  "3",
+			expectedBody: "",
+		},
+		{
+			name:         "unknown event restarts",
+			lastEventID:  "unknown",
+			expectedBody: "id:1\nevent:greeting\nretry:2000\ndata:hello\n\nid:2\ndata:multi\ndata:line\n\nid:3\nevent:bye\ndata:bye\n\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			res, body, err := s.httpClient.Do(ctx, http.MethodGet, "/events", map[string]string{
+				"Last-Event-ID": tc.lastEventID,
+			}, nil, nil)
+			s.NoError(err)
+			s.Equal(http.StatusOK, res.StatusCode)
+			s.Equal(tc.expectedBody, string(body))
+		})
+	}
+}
+
+func (s *serverTestSuite) TestSetBodySSE_DisconnectAfter() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/events", func(w httptest.ResponseWriter, r *httptest.Request) {
+		_ = w.SetBodySSE(r, httptest.SSEStream{Events: sseEvents, DisconnectAfter: 1})
+		s.Fail("SetBodySSE must not return")
+	})
+
+	res, err := http.Get(baseURL + "/events")
+	s.NoError(err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	s.Equal("id:1\nevent:greeting\nretry:2000\ndata:hello\n\n", string(body))
+}
+
+func (s *serverTestSuite) TestSetBodySSE_Delay() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/events", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.NoError(w.SetBodySSE(r, httptest.SSEStream{Events: []httptest.SSEEvent{
+			{ID: "1", Data: "now"},
+			{ID: "2", Data: "later", Delay: 200 * time.Millisecond},
+		}}))
+	})
+
+	start := time.Now()
+	res, err := http.Get(baseURL + "/events")
+	s.NoError(err)
+	defer res.Body.Close()
+
+	// The first event is received without waiting for the delayed one.
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	s.NoError(err)
+	s.Equal("id:1\n", line)
+	s.Less(time.Since(start), 200*time.Millisecond)
+
+	rest, err := io.ReadAll(reader)
+	s.NoError(err)
+	s.Equal("data:now\n\nid:2\ndata:later\n\n", string(rest))
+	s.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+}
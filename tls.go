@@ -0,0 +1,179 @@
+package httptest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+)
+
+// newTLSConfig returns the TLS config of the server.
+// If the config has no certificate, they are issued by config.CA, which is
+// generated if needed. config.CA also verifies the client certificates if
+// required and config.ClientCAs is not set.
+func newTLSConfig(address string, config *ServerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if config.TLSConfig != nil {
+		tlsConfig = config.TLSConfig.Clone()
+	}
+
+	issueCertificates := len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil
+	verifyWithCA := config.ClientAuth >= tls.VerifyClientCertIfGiven && config.ClientCAs == nil && tlsConfig.ClientCAs == nil
+	if config.CA == nil && (issueCertificates || verifyWithCA) {
+		ca, err := NewCertificateAuthority()
+		if err != nil {
+			return nil, fmt.Errorf("NewCertificateAuthority: %w", err)
+		}
+		config.CA = ca
+	}
+
+	if config.HTTP2 && !slices.Contains(tlsConfig.NextProtos, "h2") {
+		tlsConfig.NextProtos = append([]string{"h2", "http/1.1"}, tlsConfig.NextProtos...)
+	}
+
+	if config.ClientAuth != tls.NoClientCert {
+		tlsConfig.ClientAuth = config.ClientAuth
+		if config.ClientCAs != nil {
+			tlsConfig.ClientCAs = config.ClientCAs
+		} else if verifyWithCA {
+			tlsConfig.ClientCAs = config.CA.CertPool()
+		}
+	}
+
+	if !issueCertificates {
+		return tlsConfig, nil
+	}
+
+	// Clients connecting with an IP address do not send the server name,
+	// so the certificate is also valid for the listen host & loopback addresses.
+	defaultHosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(address); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			defaultHosts = append([]string{host}, defaultHosts...)
+		}
+	}
+
+	ca := config.CA
+	tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return ca.getCertificate(hello.ServerName, defaultHosts)
+	}
+
+	return tlsConfig, nil
+}
+
+// URL returns the base URL of the server, e.g. "https://127.0.0.1:3010".
+// The URL of an in-memory server is "http://in-memory", see NewInMemoryServer.
+// The URL of a server listening on a Unix domain socket is "http://localhost",
+// to be requested with Server.Client, which dials the socket.
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return inMemoryURL
+	}
+
+	scheme := "http"
+	if s.tlsConfig != nil {
+		scheme = "https"
+	}
+	if s.isUnix() {
+		return scheme + "://localhost"
+	}
+
+	return scheme + "://" + s.listener.Addr().String()
+}
+
+// CA returns the certificate authority issuing the server certificates and
+// verifying the client certificates, or nil if the server does not use one.
+func (s *Server) CA() *CertificateAuthority {
+	return s.config.CA
+}
+
+// CertPool returns a pool to trust the server certificates,
+// or nil if the server does not serve HTTPS with issued certificates.
+func (s *Server) CertPool() *x509.CertPool {
+	if s.config.CA == nil {
+		return nil
+	}
+
+	return s.config.CA.CertPool()
+}
+
+// Client returns an http.Client that trusts the server certificates.
+// The client certificates, if any, are sent to the server for mutual TLS.
+// If HTTP/2 is enabled, the client uses it, including over cleartext (h2c).
+// The client of an in-memory server uses Server.Transport, and the client of
+// a server listening on a Unix domain socket dials it for all the requests.
+func (s *Server) Client(certificates ...tls.Certificate) *http.Client {
+	if s.listener == nil {
+		return &http.Client{Transport: s.Transport()}
+	}
+	if s.config.HTTP2 && s.tlsConfig == nil {
+		return &http.Client{Transport: newH2CTransport(s.clientDialContext())}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dial := s.clientDialContext(); dial != nil {
+		transport.DialContext = dial
+	}
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:      s.CertPool(),
+		Certificates: certificates,
+	}
+	transport.ForceAttemptHTTP2 = s.config.HTTP2
+
+	return &http.Client{Transport: transport}
+}
+
+// IssueClientCertificate issues a client certificate from the server CA,
+// to be used with Client for mutual TLS.
+func (s *Server) IssueClientCertificate(commonName string, sans ...string) (tls.Certificate, error) {
+	if s.config.CA == nil {
+		return tls.Certificate{}, fmt.Errorf("the server has no CA")
+	}
+
+	return s.config.CA.IssueClientCertificate(commonName, sans...)
+}
+
+// PeerCertificate is the identity of a client certificate.
+type PeerCertificate struct {
+	// Subject is the subject distinguished name, e.g. "CN=client,O=go-http-test".
+	Subject        string   `json:"subject"`
+	CommonName     string   `json:"commonName"`
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	SerialNumber   string   `json:"serialNumber"`
+}
+
+// newPeerCertificate returns the identity of the first client certificate of
+// the connection, or nil if the client did not send one.
+func newPeerCertificate(state *tls.ConnectionState) *PeerCertificate {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := state.PeerCertificates[0]
+	peer := &PeerCertificate{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.String(),
+	}
+	for _, ip := range cert.IPAddresses {
+		peer.IPAddresses = append(peer.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		peer.URIs = append(peer.URIs, uri.String())
+	}
+
+	return peer
+}
+
+// PeerCertificate returns the identity of the client certificate,
+// or nil if the client did not send one.
+func (r *Request) PeerCertificate() *PeerCertificate {
+	return newPeerCertificate(r.TLS)
+}
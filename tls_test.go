@@ -0,0 +1,172 @@
+package httptest_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestTLS() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{TLS: true})
+	s.NoError(err)
+	defer server.Close()
+
+	s.Equal("https://"+address, server.URL())
+	s.NotNil(server.CertPool())
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.NotNil(r.TLS)
+		w.SetStatusCode(http.StatusOK)
+		w.SetBodyBytes([]byte("secure"))
+	})
+
+	client := server.Client()
+	for _, url := range []string{server.URL(), "https://localhost:3010"} {
+		res, err := client.Get(url + "/some-path")
+		s.NoError(err)
+		resBody, err := io.ReadAll(res.Body)
+		s.NoError(err)
+		s.NoError(res.Body.Close())
+		s.Equal(http.StatusOK, res.StatusCode)
+		s.Equal("secure", string(resBody))
+	}
+	s.Equal(2, server.GetNCalls(http.MethodGet, "/some-path"))
+
+	// Not trusted by the default client.
+	_, err = http.Get(server.URL() + "/some-path")
+	s.ErrorContains(err, "certificate")
+}
+
+func (s *serverTestSuite) TestTLS_CertificateValidity() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{TLS: true})
+	s.NoError(err)
+	defer server.Close()
+
+	// The certificates outlive long-running servers, e.g. the standalone binary.
+	s.True(server.CA().Certificate.NotAfter.After(time.Now().AddDate(5, 0, 0)))
+
+	res, err := server.Client().Get(server.URL() + "/some-path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	leaf := res.TLS.PeerCertificates[0]
+	s.True(leaf.NotAfter.After(time.Now().AddDate(1, 0, 0)))
+	s.True(leaf.NotAfter.Before(time.Now().AddDate(0, 0, 825)))
+}
+
+func (s *serverTestSuite) TestTLS_ProvidedCertificate() {
+	ca, err := httptest.NewCertificateAuthority()
+	s.NoError(err)
+	cert, err := ca.IssueServerCertificate("127.0.0.1")
+	s.NoError(err)
+
+	server, err := httptest.NewServer(address, httptest.ServerConfig{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	// The server does not issue the certificate, so the client must trust the CA itself.
+	s.Nil(server.CA())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.CertPool()}}}
+	res, err := client.Get(server.URL() + "/some-path")
+	s.NoError(err)
+	s.NoError(res.Body.Close())
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.True(strings.HasPrefix(string(ca.CertificatePEM()), "-----BEGIN CERTIFICATE-----"))
+}
+
+func (s *serverTestSuite) TestTLS_Disabled() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	s.Equal(baseURL, server.URL())
+	s.Nil(server.CertPool())
+}
+
+func (s *serverTestSuite) TestMutualTLS() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{
+		TLS:        true,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		s.Equal("payments", r.PeerCertificate().CommonName)
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	cert, err := server.IssueClientCertificate("payments", "payments.svc", "spiffe://test/payments", "127.0.0.1")
+	s.NoError(err)
+
+	res, err := server.Client(cert).Get(server.URL() + "/some-path")
+	s.NoError(err)
+	s.NoError(res.Body.Close())
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	calls := server.GetCalls(http.MethodGet, "/some-path")
+	s.Equal(1, len(calls))
+	s.Equal(&httptest.PeerCertificate{
+		Subject:      "CN=payments,O=go-http-test",
+		CommonName:   "payments",
+		DNSNames:     []string{"payments.svc"},
+		IPAddresses:  []string{"127.0.0.1"},
+		URIs:         []string{"spiffe://test/payments"},
+		SerialNumber: cert.Leaf.SerialNumber.String(),
+	}, calls[0].PeerCertificate)
+
+	// Without certificate.
+	_, err = server.Client().Get(server.URL() + "/some-path")
+	s.Error(err)
+
+	// Certificate from another CA.
+	otherCA, err := httptest.NewCertificateAuthority()
+	s.NoError(err)
+	otherCert, err := otherCA.IssueClientCertificate("intruder")
+	s.NoError(err)
+	_, err = server.Client(otherCert).Get(server.URL() + "/some-path")
+	s.Error(err)
+
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/some-path"))
+}
+
+func (s *serverTestSuite) TestMutualTLS_ProvidedClientCAs() {
+	clientCA, err := httptest.NewCertificateAuthority()
+	s.NoError(err)
+
+	server, err := httptest.NewServer(address, httptest.ServerConfig{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCA.CertPool(),
+	})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterHandler(http.MethodGet, "/some-path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	cert, err := clientCA.IssueClientCertificate("orders")
+	s.NoError(err)
+	res, err := server.Client(cert).Get(server.URL() + "/some-path")
+	s.NoError(err)
+	s.NoError(res.Body.Close())
+
+	// Certificate is optional.
+	res, err = server.Client().Get(server.URL() + "/some-path")
+	s.NoError(err)
+	s.NoError(res.Body.Close())
+
+	calls := server.GetCalls(http.MethodGet, "/some-path")
+	s.Equal(2, len(calls))
+	s.Equal("orders", calls[0].PeerCertificate.CommonName)
+	s.Nil(calls[1].PeerCertificate)
+}
@@ -0,0 +1,49 @@
+package httptest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// UnixScheme prefixes the address of NewServer to listen on a Unix domain
+// socket, e.g. "unix:///tmp/server.sock".
+const UnixScheme = "unix://"
+
+// listenAddress returns the network & address to listen on, from the
+// address given to NewServer and the network of the config.
+func listenAddress(address, network string) (string, string) {
+	if path, ok := strings.CutPrefix(address, UnixScheme); ok {
+		return "unix", path
+	}
+	if network == "" {
+		return "tcp", address
+	}
+
+	return network, address
+}
+
+// removeStaleSocket removes the socket file left at path, e.g. by a test
+// process that was killed before closing its server. The other files and the
+// sockets still listened on are kept, so listening fails.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	return nil
+}
+
+// isUnix returns whether the server listens on a Unix domain socket.
+func (s *Server) isUnix() bool {
+	return s.listener != nil && s.listener.Addr().Network() == "unix"
+}
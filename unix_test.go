@@ -0,0 +1,89 @@
+package httptest_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestUnixSocket() {
+	path := filepath.Join(s.T().TempDir(), "server.sock")
+	server, err := httptest.NewServer(httptest.UnixScheme+path, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+	s.Equal("http://localhost", server.URL())
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+	server.Host("slack.com").RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	res, err := server.Client().Get(server.URL() + "/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/path"))
+
+	// The hosts are dialed to the socket.
+	res, err = (&http.Client{Transport: server.HostsTransport("slack.com")}).Get("http://slack.com/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	// The socket is removed on Close.
+	_, err = os.Stat(path)
+	s.NoError(err)
+	s.NoError(server.Close())
+	_, err = os.Stat(path)
+	s.ErrorIs(err, os.ErrNotExist)
+}
+
+func (s *serverTestSuite) TestUnixSocket_Network() {
+	path := filepath.Join(s.T().TempDir(), "server.sock")
+	server, err := httptest.NewServer(path, httptest.ServerConfig{Network: "unix", TLS: true, HTTP2: true})
+	s.NoError(err)
+	defer server.Close()
+	s.Equal("https://localhost", server.URL())
+
+	server.RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusOK)
+	})
+
+	res, err := server.Client().Get(server.URL() + "/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("HTTP/2.0", res.Proto)
+}
+
+func (s *serverTestSuite) TestUnixSocket_Stale() {
+	dir := s.T().TempDir()
+
+	// A socket left by a killed process is replaced.
+	path := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", path)
+	s.NoError(err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	s.NoError(l.Close())
+	server, err := httptest.NewServer(httptest.UnixScheme+path, httptest.ServerConfig{})
+	s.NoError(err)
+
+	// A socket in use is kept.
+	_, err = httptest.NewServer(httptest.UnixScheme+path, httptest.ServerConfig{})
+	s.Error(err)
+	s.NoError(server.Close())
+
+	// Other files are kept.
+	path = filepath.Join(dir, "file")
+	s.NoError(os.WriteFile(path, []byte("data"), 0o600))
+	_, err = httptest.NewServer(httptest.UnixScheme+path, httptest.ServerConfig{})
+	s.Error(err)
+	data, err := os.ReadFile(path)
+	s.NoError(err)
+	s.Equal("data", string(data))
+}
@@ -0,0 +1,77 @@
+package httptest
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// VirtualHost is a host served by a Server, with its own handlers and calls,
+// so one Server can pose as several upstreams, e.g. "api.slack.test" and
+// "payments.test".
+// The requests are served by the virtual host of their Host header, or of
+// their TLS server name (SNI) over HTTPS. The requests of the other hosts are
+// served by the Server handlers, and the scoped requests by their Scope.
+//
+// The Server ResetCalls & ResetAll reset the virtual hosts too.
+type VirtualHost struct {
+	namespaceView
+	name string
+}
+
+// Host returns the virtual host of the name, e.g. "api.slack.test", creating it
+// on the first call. The name is case-insensitive, without port, and may start
+// with a "*." wildcard matching any subdomain, e.g. "*.slack.test"; the exact
+// names have priority over the wildcards.
+func (s *Server) Host(name string) *VirtualHost {
+	name = strings.ToLower(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.hosts[name]
+	if !ok {
+		ns = newNamespace()
+		s.hosts[name] = ns
+	}
+
+	return &VirtualHost{namespaceView: namespaceView{server: s, ns: ns}, name: name}
+}
+
+// Name returns the virtual host name.
+func (h *VirtualHost) Name() string {
+	return h.name
+}
+
+// hostNamespace returns the namespace of the virtual host of the request, or
+// the Server one if it has none.
+// The caller must hold s.mu.
+func (s *Server) hostNamespace(r *http.Request) *namespace {
+	if len(s.hosts) == 0 {
+		return s.namespace
+	}
+
+	host := r.Host
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		host = r.TLS.ServerName
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if ns, ok := s.hosts[host]; ok {
+		return ns
+	}
+	// Try the wildcards, from the longest domain, e.g. "*.a.b.test" then "*.b.test".
+	for domain := host; ; {
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return s.namespace
+		}
+		if ns, ok := s.hosts["*."+parent]; ok {
+			return ns
+		}
+		domain = parent
+	}
+}
@@ -0,0 +1,108 @@
+package httptest_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+func (s *serverTestSuite) TestVirtualHost() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	respond := func(status int) httptest.ServerHandlerFunc {
+		return func(w httptest.ResponseWriter, r *httptest.Request) {
+			w.SetStatusCode(status)
+		}
+	}
+	server.RegisterHandler(http.MethodGet, "/path", respond(http.StatusOK))
+	slack := server.Host("api.slack.test")
+	slack.RegisterHandler(http.MethodGet, "/path", respond(http.StatusAccepted))
+	payments := server.Host("*.payments.TEST")
+	payments.RegisterHandler(http.MethodGet, "/path", respond(http.StatusCreated))
+	s.Equal("*.payments.test", payments.Name())
+
+	testCases := []struct {
+		host           string
+		expectedStatus int
+	}{
+		{"api.slack.test", http.StatusAccepted},
+		{"API.Slack.Test:3010", http.StatusAccepted},
+		{"eu.payments.test", http.StatusCreated},
+		{"a.eu.payments.test", http.StatusCreated},
+		{"payments.test", http.StatusOK},
+		{"127.0.0.1:3010", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/path", nil)
+		s.NoError(err)
+		req.Host = tc.host
+		res, err := http.DefaultClient.Do(req)
+		s.NoError(err)
+		_ = res.Body.Close()
+		s.Equal(tc.expectedStatus, res.StatusCode, tc.host)
+	}
+
+	// The virtual hosts have their own calls.
+	s.Equal(2, slack.GetNCalls(http.MethodGet, "/path"))
+	s.Len(payments.GetCalls(http.MethodGet, "/path"), 2)
+	s.Equal(2, server.GetNCalls(http.MethodGet, "/path"))
+
+	// Host returns the same virtual host.
+	s.Equal(2, server.Host("api.slack.test").GetNCalls(http.MethodGet, "/path"))
+
+	server.ResetCalls()
+	s.Equal(0, slack.GetNCalls(http.MethodGet, "/path"))
+
+	server.ResetAll()
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/path", nil)
+	s.NoError(err)
+	req.Host = "api.slack.test"
+	res, err := http.DefaultClient.Do(req)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (s *serverTestSuite) TestVirtualHost_TLSServerName() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{TLS: true})
+	s.NoError(err)
+	defer server.Close()
+
+	server.Host("api.slack.test").RegisterHandler(http.MethodGet, "/path", func(w httptest.ResponseWriter, r *httptest.Request) {
+		w.SetStatusCode(http.StatusAccepted)
+	})
+
+	// Connect to the server for any host, the server name is the requested host.
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	res, err := client.Get("https://api.slack.test/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+	s.Equal("api.slack.test", res.TLS.ServerName)
+
+	// The server name has priority over the Host header.
+	req, err := http.NewRequest(http.MethodGet, "https://api.slack.test/path", nil)
+	s.NoError(err)
+	req.Host = "other.test"
+	res, err = client.Do(req)
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusAccepted, res.StatusCode)
+
+	transport.TLSClientConfig = &tls.Config{RootCAs: server.CertPool(), ServerName: "other.test"}
+	transport.CloseIdleConnections()
+	res, err = client.Get("https://api.slack.test/path")
+	s.NoError(err)
+	_ = res.Body.Close()
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,136 @@
+package httptest
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// mappingsReloadDelay is how long the watcher waits for the file changes to
+// settle before reloading, as editors often write a file in several steps.
+const mappingsReloadDelay = 100 * time.Millisecond
+
+// mappingsWatcher reloads the mappings of a directory when its files change.
+type mappingsWatcher struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	// ids are the IDs of the mappings loaded from dir.
+	ids []string
+}
+
+// WatchMappings loads the mappings in dir like LoadMappings, then watches dir
+// and replaces them whenever the *.json files change.
+// All the routes are added at once, so requests never see a partially loaded directory.
+// If the changed files are invalid, the error is logged and the previous mappings are kept.
+// The watcher is stopped when the server is closed.
+func (s *Server) WatchMappings(dir string) error {
+	w := &mappingsWatcher{dir: dir}
+	if err := s.reloadMappings(w); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("fsnotify.NewWatcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("watcher.Add: %w", err)
+	}
+	w.watcher = watcher
+
+	s.mu.Lock()
+	s.watchers = append(s.watchers, w)
+	s.mu.Unlock()
+
+	go s.watchMappings(w)
+
+	return nil
+}
+
+// watchMappings reloads the mappings on file changes until the watcher is closed.
+func (s *Server) watchMappings(w *mappingsWatcher) {
+	reload := make(chan struct{}, 1)
+	timer := time.AfterFunc(time.Hour, func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	})
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Ext(event.Name) != ".json" || event.Has(fsnotify.Chmod) {
+				continue
+			}
+			timer.Reset(mappingsReloadDelay)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.config.Logger.Printf("watch mappings %s: %v", w.dir, err)
+		case <-reload:
+			if err := s.reloadMappings(w); err != nil {
+				s.config.Logger.Printf("reload mappings %s, keeping the previous mappings: %v", w.dir, err)
+				continue
+			}
+			s.config.Logger.Printf("reloaded %d mappings from %s", len(w.ids), w.dir)
+		}
+	}
+}
+
+// reloadMappings replaces the mappings previously loaded from the watcher directory.
+// Nothing is changed if the directory cannot be loaded.
+func (s *Server) reloadMappings(w *mappingsWatcher) error {
+	mappings, err := ReadMappings(w.dir)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldMappings := slices.Clone(s.mappings)
+	var removed []Mapping
+	s.mappings = slices.DeleteFunc(s.mappings, func(m Mapping) bool {
+		if slices.Contains(w.ids, m.ID) {
+			removed = append(removed, m)
+			return true
+		}
+		return false
+	})
+	ids := make([]string, 0, len(mappings))
+	routes := make([]methodRoute, 0, len(mappings))
+	for _, m := range mappings {
+		ids = append(ids, s.addMapping(m))
+		routes = append(routes, methodRoute{
+			method: m.Request.Method,
+			route:  s.mappingsRoute(m.Request.Method, m.Request.Path),
+		})
+	}
+	// The routes left without mappings are unregistered, like with RemoveMapping.
+	var removeIDs []string
+	for _, m := range removed {
+		if !s.hasMapping(m.Request.Method, m.Request.Path) {
+			removeIDs = append(removeIDs, mappingsRouteID(m.Request.Method, m.Request.Path))
+		}
+	}
+
+	// Update all the routes at once, so the requests see either all or none of the changes.
+	if err := s.router.update(routes, removeIDs); err != nil {
+		s.mappings = oldMappings
+		return err
+	}
+	w.ids = ids
+
+	return nil
+}
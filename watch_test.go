@@ -0,0 +1,108 @@
+package httptest_test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+// syncBuffer is a bytes.Buffer safe to be written by the server logger while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (s *serverTestSuite) TestWatchMappings() {
+	dir := s.T().TempDir()
+	file := filepath.Join(dir, "users.json")
+	s.NoError(os.WriteFile(file, []byte(`{
+		"request": {"method": "GET", "path": "/users"},
+		"response": {"body": "v1"}
+	}`), 0o644))
+
+	logs := &syncBuffer{}
+	server, err := httptest.NewServer(address, httptest.ServerConfig{Logger: log.New(logs, "", 0)})
+	s.NoError(err)
+	defer server.Close()
+
+	s.NoError(server.WatchMappings(dir))
+
+	getBody := func(path string) string {
+		_, resBody, err := s.httpClient.Do(ctx, http.MethodGet, path, nil, nil, nil)
+		s.NoError(err)
+		return string(resBody)
+	}
+	s.Equal("v1", getBody("/users"))
+
+	s.NoError(os.WriteFile(file, []byte(`{"mappings": [
+		{"request": {"method": "GET", "path": "/users"}, "response": {"body": "v2"}},
+		{"request": {"method": "GET", "path": "/orders"}, "response": {"body": "orders"}}
+	]}`), 0o644))
+	s.Eventually(func() bool {
+		return getBody("/users") == "v2"
+	}, 2*time.Second, 20*time.Millisecond)
+	s.Equal("orders", getBody("/orders"))
+	s.Len(server.GetMappings(), 2)
+
+	// Invalid file keeps the previous mappings.
+	s.NoError(os.WriteFile(file, []byte(`{"request": `), 0o644))
+	s.Eventually(func() bool {
+		return strings.Contains(logs.String(), "keeping the previous mappings")
+	}, 2*time.Second, 20*time.Millisecond)
+	s.Equal("v2", getBody("/users"))
+	s.Len(server.GetMappings(), 2)
+
+	// The routes of the dropped mappings are unregistered.
+	s.NoError(os.WriteFile(file, []byte(`{
+		"request": {"method": "GET", "path": "/users"},
+		"response": {"body": "v3"}
+	}`), 0o644))
+	s.Eventually(func() bool {
+		return getBody("/users") == "v3"
+	}, 2*time.Second, 20*time.Millisecond)
+	server.ResetCalls()
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/orders", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/orders"))
+
+	// The routes of a deleted file too.
+	s.NoError(os.Remove(file))
+	s.Eventually(func() bool {
+		res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/users", nil, nil, nil)
+		return err == nil && res.StatusCode == http.StatusNotFound
+	}, 2*time.Second, 20*time.Millisecond)
+	s.Empty(server.GetMappings())
+	server.ResetCalls()
+	res, _, err = s.httpClient.Do(ctx, http.MethodGet, "/users", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	s.Equal(0, server.GetNCalls(http.MethodGet, "/users"))
+}
+
+func (s *serverTestSuite) TestWatchMappings_InvalidDir() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	s.Error(server.WatchMappings(filepath.Join(s.T().TempDir(), "not-found")))
+}
@@ -0,0 +1,289 @@
+package httptest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket frame types, see WebSocketFrame.Type.
+const (
+	WebSocketText   = "text"
+	WebSocketBinary = "binary"
+	WebSocketClose  = "close"
+)
+
+// WebSocket frame directions, see WebSocketFrame.Direction.
+const (
+	WebSocketReceived = "received"
+	WebSocketSent     = "sent"
+)
+
+// closeTimeout is how long to wait for the close frame to be written.
+const closeTimeout = time.Second
+
+// WebSocketFrame is a frame received or sent on a WebSocket connection.
+type WebSocketFrame struct {
+	// Conn is the number of the connection on the path, starting from 1.
+	Conn int `json:"conn"`
+	// Direction is WebSocketReceived or WebSocketSent.
+	Direction string `json:"direction"`
+	// Type is WebSocketText, WebSocketBinary or WebSocketClose.
+	Type string `json:"type"`
+	Data []byte `json:"data,omitempty"`
+	// CloseCode is the code of a close frame, e.g. websocket.CloseNormalClosure.
+	CloseCode int `json:"closeCode,omitempty"`
+}
+
+// Text returns the data of the frame as a string.
+func (f WebSocketFrame) Text() string {
+	return string(f.Data)
+}
+
+// WebSocketCloseError is returned by WebSocketConn.Receive when the client closes the connection.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WebSocketHandlerFunc is the interface of the WebSocket handler function.
+// The connection is closed with websocket.CloseNormalClosure when it returns,
+// unless it is already closed.
+type WebSocketHandlerFunc func(conn *WebSocketConn, r *Request)
+
+// WebSocketConn is a WebSocket connection, every frame received or sent
+// is recorded, see Server.GetWebSocketFrames.
+type WebSocketConn struct {
+	conn   *websocket.Conn
+	server *Server
+	path   string
+	number int
+
+	// mu serializes the writes, gorilla/websocket supports one writer at a time.
+	mu sync.Mutex
+	// closeSent is set once a close frame is sent, by Close or in reply to the client.
+	closeSent bool
+}
+
+// Receive waits for the next text or binary message.
+// It returns a *WebSocketCloseError if the client closes the connection.
+func (c *WebSocketConn) Receive() (WebSocketFrame, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return WebSocketFrame{}, &WebSocketCloseError{Code: closeErr.Code, Reason: closeErr.Text}
+		}
+		return WebSocketFrame{}, fmt.Errorf("websocket.ReadMessage: %w", err)
+	}
+
+	frameType := WebSocketText
+	if messageType == websocket.BinaryMessage {
+		frameType = WebSocketBinary
+	}
+
+	return c.record(WebSocketReceived, frameType, data, 0), nil
+}
+
+// SendText sends a text message.
+func (c *WebSocketConn) SendText(text string) error {
+	return c.send(websocket.TextMessage, WebSocketText, []byte(text))
+}
+
+// SendBinary sends a binary message.
+func (c *WebSocketConn) SendBinary(data []byte) error {
+	return c.send(websocket.BinaryMessage, WebSocketBinary, data)
+}
+
+func (c *WebSocketConn) send(messageType int, frameType string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Recorded before writing, so the frame is in the journal once the client gets it.
+	c.record(WebSocketSent, frameType, data, 0)
+	if err := c.conn.WriteMessage(messageType, data); err != nil {
+		return fmt.Errorf("websocket.WriteMessage: %w", err)
+	}
+
+	return nil
+}
+
+// Close sends a close frame with the code, e.g. websocket.CloseGoingAway,
+// and closes the connection. The close frame is not sent again if it was
+// already, e.g. in reply to the client closing first.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeSent {
+		// The hijacked connection is not closed by the http.Server, close it anyway.
+		_ = c.conn.Close()
+		return nil
+	}
+	c.closeSent = true
+
+	// Recorded before writing, so the frame is in the journal once the client gets it.
+	c.record(WebSocketSent, WebSocketClose, []byte(reason), code)
+	err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeTimeout))
+	_ = c.conn.Close()
+	if err != nil {
+		return fmt.Errorf("websocket.WriteControl: %w", err)
+	}
+
+	return nil
+}
+
+// handleClose records the close frame of the client, and echoes it like
+// the default close handler of gorilla/websocket. The connection is closed
+// once the handler returns.
+func (c *WebSocketConn) handleClose(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.record(WebSocketReceived, WebSocketClose, []byte(reason), code)
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(closeTimeout))
+
+	return nil
+}
+
+// record records the frame in the server journal, and returns it.
+func (c *WebSocketConn) record(direction, frameType string, data []byte, closeCode int) WebSocketFrame {
+	frame := WebSocketFrame{
+		Conn:      c.number,
+		Direction: direction,
+		Type:      frameType,
+		Data:      data,
+		CloseCode: closeCode,
+	}
+
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	c.server.webSocketFrames[c.path] = append(c.server.webSocketFrames[c.path], frame)
+
+	return frame
+}
+
+// WebSocketScript is a scripted WebSocket conversation.
+type WebSocketScript struct {
+	// OnConnect are the text messages sent once connected.
+	OnConnect []string
+	// Rules answer the received text messages, the first matching rule is used.
+	// The messages matching no rule are ignored.
+	Rules []WebSocketRule
+}
+
+// WebSocketRule answers a received text message.
+type WebSocketRule struct {
+	// On is the message to answer. Empty matches any message.
+	On string
+	// Reply are the text messages sent in response.
+	Reply []string
+	// CloseCode, if set, closes the connection with it after replying.
+	CloseCode   int
+	CloseReason string
+}
+
+// Handler returns the WebSocketHandlerFunc playing the script,
+// until the client closes the connection or a rule closes it.
+func (s WebSocketScript) Handler() WebSocketHandlerFunc {
+	return func(conn *WebSocketConn, r *Request) {
+		for _, text := range s.OnConnect {
+			if err := conn.SendText(text); err != nil {
+				return
+			}
+		}
+
+		for {
+			frame, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			rule, ok := s.match(frame)
+			if !ok {
+				continue
+			}
+			for _, text := range rule.Reply {
+				if err := conn.SendText(text); err != nil {
+					return
+				}
+			}
+			if rule.CloseCode != 0 {
+				_ = conn.Close(rule.CloseCode, rule.CloseReason)
+				return
+			}
+		}
+	}
+}
+
+func (s WebSocketScript) match(frame WebSocketFrame) (WebSocketRule, bool) {
+	if frame.Type != WebSocketText {
+		return WebSocketRule{}, false
+	}
+	for _, rule := range s.Rules {
+		if rule.On == "" || rule.On == frame.Text() {
+			return rule, true
+		}
+	}
+
+	return WebSocketRule{}, false
+}
+
+// RegisterWebSocket registers a WebSocket handler of a path, for GET requests.
+// The upgrade request is recorded like the other calls, and the frames with
+// Server.GetWebSocketFrames.
+// Registering same path twice will overwrite the previous handler.
+func (s *Server) RegisterWebSocket(path string, handler WebSocketHandlerFunc) {
+	upgrader := websocket.Upgrader{
+		// Accept any origin, the clients are tests.
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	s.RegisterHandler(http.MethodGet, path, func(w ResponseWriter, r *Request) {
+		// Upgrade replies with an error if the request is not a WebSocket handshake.
+		ws, err := upgrader.Upgrade(w.w, r.Request, nil)
+		if err != nil {
+			return
+		}
+		// Closed with the server, the hijacked connections are not closed by http.Server.Close.
+		tracked, ok := s.trackHijacked(ws.UnderlyingConn())
+		if !ok {
+			_ = ws.Close()
+			return
+		}
+		defer tracked.Close()
+
+		s.mu.Lock()
+		s.webSocketConns[path]++
+		number := s.webSocketConns[path]
+		s.mu.Unlock()
+
+		conn := &WebSocketConn{conn: ws, server: s, path: path, number: number}
+		ws.SetCloseHandler(conn.handleClose)
+		defer conn.Close(websocket.CloseNormalClosure, "")
+
+		handler(conn, r)
+	})
+}
+
+// GetWebSocketFrames returns the frames received and sent on the WebSocket
+// connections of a path, in order.
+func (s *Server) GetWebSocketFrames(path string) []WebSocketFrame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]WebSocketFrame(nil), s.webSocketFrames[path]...)
+}
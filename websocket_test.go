@@ -0,0 +1,225 @@
+package httptest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	httptest "github.com/slzhffktm/go-http-test"
+)
+
+const wsURL = "ws://127.0.0.1:3010"
+
+func (s *serverTestSuite) TestRegisterWebSocket() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws/:room", func(conn *httptest.WebSocketConn, r *httptest.Request) {
+		s.NoError(conn.SendText("welcome to " + r.Params.ByName("room")))
+		for {
+			frame, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			if frame.Type == httptest.WebSocketBinary {
+				s.NoError(conn.SendBinary(append([]byte{0}, frame.Data...)))
+				continue
+			}
+			s.NoError(conn.SendText("echo: " + frame.Text()))
+		}
+	})
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws/lobby", nil)
+	s.NoError(err)
+
+	_, msg, err := client.ReadMessage()
+	s.NoError(err)
+	s.Equal("welcome to lobby", string(msg))
+
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, msg, err = client.ReadMessage()
+	s.NoError(err)
+	s.Equal("echo: hello", string(msg))
+
+	s.NoError(client.WriteMessage(websocket.BinaryMessage, []byte{1, 2}))
+	messageType, msg, err := client.ReadMessage()
+	s.NoError(err)
+	s.Equal(websocket.BinaryMessage, messageType)
+	s.Equal([]byte{0, 1, 2}, msg)
+
+	s.NoError(client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")))
+	_, _, err = client.ReadMessage()
+	s.True(websocket.IsCloseError(err, websocket.CloseNormalClosure))
+	s.NoError(client.Close())
+
+	s.Equal(1, server.GetNCalls(http.MethodGet, "/ws/:room"))
+	s.Equal([]httptest.WebSocketFrame{
+		{Conn: 1, Direction: httptest.WebSocketSent, Type: httptest.WebSocketText, Data: []byte("welcome to lobby")},
+		{Conn: 1, Direction: httptest.WebSocketReceived, Type: httptest.WebSocketText, Data: []byte("hello")},
+		{Conn: 1, Direction: httptest.WebSocketSent, Type: httptest.WebSocketText, Data: []byte("echo: hello")},
+		{Conn: 1, Direction: httptest.WebSocketReceived, Type: httptest.WebSocketBinary, Data: []byte{1, 2}},
+		{Conn: 1, Direction: httptest.WebSocketSent, Type: httptest.WebSocketBinary, Data: []byte{0, 1, 2}},
+		{Conn: 1, Direction: httptest.WebSocketReceived, Type: httptest.WebSocketClose, Data: []byte("bye"), CloseCode: websocket.CloseNormalClosure},
+	}, server.GetWebSocketFrames("/ws/:room"))
+}
+
+func (s *serverTestSuite) TestRegisterWebSocket_ClientClosesFirst() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws", func(conn *httptest.WebSocketConn, r *httptest.Request) {
+		for {
+			if _, err := conn.Receive(); err != nil {
+				return
+			}
+		}
+	})
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", nil)
+	s.NoError(err)
+	defer client.Close()
+
+	s.NoError(client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	_, _, err = client.ReadMessage()
+	s.True(websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	// The server closes the connection after the close handshake.
+	raw := client.UnderlyingConn()
+	s.NoError(raw.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = raw.Read(make([]byte, 1))
+	s.ErrorIs(err, io.EOF)
+}
+
+func (s *serverTestSuite) TestRegisterWebSocket_ServerClose() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws", httptest.WebSocketScript{
+		Rules: []httptest.WebSocketRule{{On: "ping", Reply: []string{"pong"}}},
+	}.Handler())
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", nil)
+	s.NoError(err)
+	defer client.Close()
+
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("ping")))
+	_, msg, err := client.ReadMessage()
+	s.NoError(err)
+	s.Equal("pong", string(msg))
+
+	// Close closes the upgraded connections.
+	s.NoError(server.Close())
+	s.NoError(client.SetReadDeadline(time.Now().Add(time.Second)))
+	_ = client.WriteMessage(websocket.TextMessage, []byte("ping"))
+	_, _, err = client.ReadMessage()
+	s.Error(err)
+	s.False(errors.Is(err, os.ErrDeadlineExceeded), err)
+	var received int
+	for _, frame := range server.GetWebSocketFrames("/ws") {
+		if frame.Direction == httptest.WebSocketReceived {
+			received++
+		}
+	}
+	s.Equal(1, received)
+}
+
+func (s *serverTestSuite) TestRegisterWebSocket_Script() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws", httptest.WebSocketScript{
+		OnConnect: []string{"ready"},
+		Rules: []httptest.WebSocketRule{
+			{On: "ping", Reply: []string{"pong"}},
+			{On: "subscribe", Reply: []string{"subscribed", "tick 1", "tick 2"}},
+			{On: "quit", Reply: []string{"bye"}, CloseCode: websocket.CloseGoingAway, CloseReason: "server going away"},
+		},
+	}.Handler())
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", nil)
+	s.NoError(err)
+	defer client.Close()
+
+	read := func() string {
+		_, msg, err := client.ReadMessage()
+		s.NoError(err)
+		return string(msg)
+	}
+
+	s.Equal("ready", read())
+
+	// Not matching any rule, ignored.
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("unknown")))
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("ping")))
+	s.Equal("pong", read())
+
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("subscribe")))
+	s.Equal("subscribed", read())
+	s.Equal("tick 1", read())
+	s.Equal("tick 2", read())
+
+	s.NoError(client.WriteMessage(websocket.TextMessage, []byte("quit")))
+	s.Equal("bye", read())
+	_, _, err = client.ReadMessage()
+	var closeErr *websocket.CloseError
+	s.ErrorAs(err, &closeErr)
+	s.Equal(websocket.CloseGoingAway, closeErr.Code)
+	s.Equal("server going away", closeErr.Text)
+
+	frames := server.GetWebSocketFrames("/ws")
+	last := frames[len(frames)-1]
+	s.Equal(httptest.WebSocketSent, last.Direction)
+	s.Equal(httptest.WebSocketClose, last.Type)
+	s.Equal(websocket.CloseGoingAway, last.CloseCode)
+}
+
+func (s *serverTestSuite) TestRegisterWebSocket_ConnNumbersAndReset() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws", httptest.WebSocketScript{OnConnect: []string{"hi"}}.Handler())
+
+	for range 2 {
+		client, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", nil)
+		s.NoError(err)
+		_, _, err = client.ReadMessage()
+		s.NoError(err)
+		// Wait for the close frame echoed by the server, so the journal is complete.
+		s.NoError(client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+		_, _, err = client.ReadMessage()
+		s.True(websocket.IsCloseError(err, websocket.CloseNormalClosure))
+		s.NoError(client.Close())
+	}
+
+	var conns []int
+	for _, frame := range server.GetWebSocketFrames("/ws") {
+		conns = append(conns, frame.Conn)
+	}
+	s.Equal([]int{1, 1, 2, 2}, conns)
+
+	server.ResetCalls()
+	s.Empty(server.GetWebSocketFrames("/ws"))
+}
+
+func (s *serverTestSuite) TestRegisterWebSocket_NotAHandshake() {
+	server, err := httptest.NewServer(address, httptest.ServerConfig{})
+	s.NoError(err)
+	defer server.Close()
+
+	server.RegisterWebSocket("/ws", func(conn *httptest.WebSocketConn, r *httptest.Request) {
+		s.Fail("handler must not be called")
+	})
+
+	res, _, err := s.httpClient.Do(ctx, http.MethodGet, "/ws", nil, nil, nil)
+	s.NoError(err)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}